@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+
+	cloudfrontclient "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/cloudfront"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/discovery"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/metrics"
+)
+
+const (
+	// RoleSessionName identifies the sessions of roles which are assumed.
+	RoleSessionName = "cloudfront-invalidation-metrics"
+)
+
+// Account which metrics are collected from.
+type Account struct {
+	// ID of the account, which is empty for the account the Lambda runs in.
+	ID         string
+	CloudFront cloudfrontclient.ClientInterface
+}
+
+// accountsFromEnv loads the accounts which metrics are collected from.
+// When no roles are configured and discovery is off, only the account the
+// Lambda runs in is.
+func accountsFromEnv(ctx context.Context, cfg aws.Config) ([]Account, error) {
+	roleARNs := splitList(os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_ROLE_ARNS"))
+
+	if discoverFromEnv() {
+		if len(roleARNs) > 0 {
+			return nil, fmt.Errorf("role arns cannot be configured with discovery")
+		}
+
+		role := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_ORGANIZATION_ROLE")
+		if role == "" {
+			role = discovery.DefaultRole
+		}
+
+		tags, err := parsePairs(os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_ORGANIZATION_TAGS"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse organization tags: %w", err)
+		}
+
+		discoverer, err := discovery.New(organizations.NewFromConfig(cfg), splitList(os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_ORGANIZATION_UNITS")), tags)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup discovery: %w", err)
+		}
+
+		return discoverAccounts(ctx, cfg, discoverer, sts.NewFromConfig(cfg), role)
+	}
+
+	if len(roleARNs) == 0 {
+		return []Account{
+			{
+				CloudFront: cloudfront.NewFromConfig(cfg),
+			},
+		}, nil
+	}
+
+	return assumeRoles(cfg, roleARNs)
+}
+
+// assumeRoles returns an account for each role, with clients which use
+// credentials from assuming it.
+func assumeRoles(cfg aws.Config, roleARNs []string) ([]Account, error) {
+	client := sts.NewFromConfig(cfg)
+
+	accounts := make([]Account, len(roleARNs))
+
+	for i, roleARN := range roleARNs {
+		parsed, err := arn.Parse(roleARN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse role arn: %s: %w", roleARN, err)
+		}
+
+		accounts[i] = Account{
+			ID:         parsed.AccountID,
+			CloudFront: cloudfront.NewFromConfig(assumeRole(cfg, client, roleARN)),
+		}
+	}
+
+	return accounts, nil
+}
+
+// discoverFromEnv reports whether accounts are discovered with AWS
+// Organizations rather than configured.
+func discoverFromEnv() bool {
+	return os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_DISCOVER") != ""
+}
+
+// discoverAccounts returns an account for each account of the organization
+// which the role can be assumed in. Accounts without the role are skipped and
+// reported, as are those which do not trust the Lambda to assume it, so that
+// a new account does not fail every run until the role is deployed to it.
+func discoverAccounts(ctx context.Context, cfg aws.Config, discoverer *discovery.Discoverer, client stscreds.AssumeRoleAPIClient, role string) ([]Account, error) {
+	discovered, err := discoverer.Accounts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover accounts: %w", err)
+	}
+
+	if len(discovered) == 0 {
+		return nil, fmt.Errorf("no accounts were discovered")
+	}
+
+	var accounts []Account
+
+	for _, account := range discovered {
+		roleARN := discovery.RoleARN(account, role)
+
+		assumed := assumeRole(cfg, client, roleARN)
+
+		// Credentials are cached, so they are reused when collecting.
+		_, err := assumed.Credentials.Retrieve(ctx)
+		if err != nil {
+			if !roleMissing(err) {
+				return nil, fmt.Errorf("failed to assume role: %s: %w", roleARN, err)
+			}
+
+			log.Printf("skipping account %s: unable to assume role %s: %v", aws.ToString(account.Id), roleARN, err)
+
+			continue
+		}
+
+		accounts = append(accounts, Account{
+			ID:         aws.ToString(account.Id),
+			CloudFront: cloudfront.NewFromConfig(assumed),
+		})
+	}
+
+	// When no role can be assumed it is more likely that the Lambda's own
+	// role cannot assume roles, than that every account is missing one.
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("role %s could not be assumed in any of the %d discovered accounts", role, len(discovered))
+	}
+
+	return accounts, nil
+}
+
+// roleMissing reports whether assuming a role was denied, which STS also
+// returns when the role does not exist.
+func roleMissing(err error) bool {
+	var apiErr smithy.APIError
+
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDenied"
+}
+
+// assumeRole returns a copy of the config which uses credentials from
+// assuming the role, refreshed as they expire.
+func assumeRole(cfg aws.Config, client stscreds.AssumeRoleAPIClient, roleARN string) aws.Config {
+	cfg = cfg.Copy()
+
+	cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(client, roleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = RoleSessionName
+	}))
+
+	return cfg
+}
+
+// ExecuteAccounts runs Execute for each account in turn. A failure for one
+// account does not stop the rest, and every failure is returned together.
+func ExecuteAccounts(ctx context.Context, accounts []Account, client metrics.ClientInterface, scheduled time.Time, options Options) error {
+	var errs []error
+
+	for _, account := range accounts {
+		options.AccountID = account.ID
+
+		err := Execute(ctx, account.CloudFront, client, scheduled, options)
+		if err != nil {
+			if account.ID != "" {
+				err = fmt.Errorf("account %s: %w", account.ID, err)
+			}
+
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,482 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	cftypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+
+	cloudfrontclient "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/cloudfront"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/billing"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/paths"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/sources"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/state"
+)
+
+const (
+	// StatusInProgress is the status of an invalidation which is in progress.
+	StatusInProgress = "InProgress"
+	// StatusCompleted is the status of an invalidation which has completed.
+	StatusCompleted = "Completed"
+)
+
+const (
+	// DefaultPathQuota is how many paths CloudFront allows to be in progress
+	// for a distribution at once.
+	DefaultPathQuota = 3000
+	// DefaultWildcardQuota is how many wildcard paths CloudFront allows to be
+	// in progress for a distribution at once.
+	DefaultWildcardQuota = 15
+)
+
+// distributionResult holds the counts collected for a single distribution.
+type distributionResult struct {
+	DistributionID string
+	// Dimensions which are added to every metric for this distribution.
+	Dimensions    []types.Dimension
+	Invalidations float64
+	Paths         float64
+	// PathGroups counts the paths invalidated in each configured group.
+	PathGroups map[string]float64
+	// Sources counts the invalidations and paths attributed to each source.
+	Sources       map[string]sourceResult
+	WildcardPaths float64
+	FullPurges    float64
+	InProgress    float64
+	Completed     float64
+	// CompletionsTracked is whether Completed and CompletionSeconds are
+	// known, which needs the invalidations in progress at the previous run.
+	CompletionsTracked bool
+	// PathsInProgress and WildcardPathsInProgress count the paths of in
+	// progress invalidations, which CloudFront limits by quotas.
+	PathsInProgress          float64
+	WildcardPathsInProgress  float64
+	PathQuotaUtilisation     float64
+	WildcardQuotaUtilisation float64
+	// MonthPaths counts the paths invalidated within the window which are
+	// billed against the month of the window.
+	MonthPaths float64
+	// Billing for the distribution, when month to date paths are tracked.
+	Billing *billingResult
+	// CompletionSeconds is how long each completed invalidation took,
+	// measured to the end of the window in which it was seen complete.
+	CompletionSeconds []float64
+	// State to persist once the metrics have been pushed.
+	State state.State
+}
+
+// sourceResult holds the counts attributed to a single source.
+type sourceResult struct {
+	Invalidations float64
+	Paths         float64
+}
+
+// collector gathers invalidation counts from CloudFront.
+type collector struct {
+	CloudFront cloudfrontclient.ClientInterface
+	// Store holds checkpoints from previous runs. When nil every run
+	// counts the invalidations created within the Window.
+	Store state.StoreInterface
+	// Window being measured. Invalidations created after the end of the
+	// window are left for the next run.
+	Window Window
+	// Lookback is how long before the end of the window invalidations are
+	// checked for their status.
+	Lookback time.Duration
+	// PathGroups classify invalidated paths by their prefix.
+	PathGroups paths.Groups
+	// Sources attribute invalidations to a source by their caller reference.
+	Sources sources.Rules
+	// TagDimensions are the keys of distribution tags which are added to
+	// every metric as dimensions.
+	TagDimensions []string
+	// NameDimension is how distributions are named by a human-readable
+	// dimension, when set.
+	NameDimension string
+	// AccountID is added to every metric as a dimension, when set.
+	AccountID string
+	// PathQuota and WildcardQuota are how many paths and wildcard paths
+	// can be in progress for a distribution at once.
+	PathQuota     float64
+	WildcardQuota float64
+}
+
+// collectAll fans the per-distribution work out to a pool of workers
+// bounded by concurrency. Results are returned in the same order as the
+// distributions so the metrics pushed are deterministic.
+func (c collector) collectAll(ctx context.Context, distributions []cftypes.DistributionSummary, concurrency int) ([]distributionResult, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		results  = make([]distributionResult, len(distributions))
+		jobs     = make(chan int)
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				result, err := c.collect(ctx, distributions[job])
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+
+					continue
+				}
+
+				results[job] = result
+			}
+		}()
+	}
+
+dispatch:
+	for i := range distributions {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// collect counts the invalidations and paths for a single distribution
+// which were created after its checkpoint, and the status of invalidations
+// created within the lookback.
+func (c collector) collect(ctx context.Context, distribution cftypes.DistributionSummary) (distributionResult, error) {
+	result := distributionResult{
+		DistributionID: aws.ToString(distribution.Id),
+		Dimensions:     accountDimensions(c.AccountID),
+	}
+
+	if dimension := nameDimension(c.NameDimension, distribution); dimension != nil {
+		result.Dimensions = append(result.Dimensions, *dimension)
+	}
+
+	if len(c.TagDimensions) > 0 {
+		dimensions, err := c.tagDimensions(ctx, distribution)
+		if err != nil {
+			return result, fmt.Errorf("failed to list tags: %w", err)
+		}
+
+		result.Dimensions = append(result.Dimensions, dimensions...)
+	}
+
+	// Every group is published, even when nothing was invalidated in it.
+	if len(c.PathGroups) > 0 {
+		result.PathGroups = make(map[string]float64)
+
+		for _, name := range c.PathGroups.Names() {
+			result.PathGroups[name] = 0
+		}
+	}
+
+	if c.Store != nil {
+		previous, err := c.Store.Get(ctx, result.DistributionID)
+		if err != nil {
+			return result, fmt.Errorf("failed to get state: %w", err)
+		}
+
+		result.State = previous
+	}
+
+	// Fall back to the window when this distribution has not been seen before.
+	checkpoint := result.State.Checkpoint
+	if checkpoint.IsZero() {
+		checkpoint = state.Checkpoint{
+			CreateTime: c.Window.Start,
+		}
+	}
+
+	invalidations, included, err := listInvalidations(ctx, c.CloudFront, distribution.Id, checkpoint, c.Window.End, c.Window.End.Add(-c.Lookback))
+	if err != nil {
+		return result, fmt.Errorf("failed to list invalidations: %w", err)
+	}
+
+	// Details are needed for both in progress and counted invalidations,
+	// so each is only fetched once.
+	details := make(map[string]*cftypes.Invalidation)
+
+	getInvalidation := func(id *string) (*cftypes.Invalidation, error) {
+		if detail, ok := details[aws.ToString(id)]; ok {
+			return detail, nil
+		}
+
+		output, err := c.CloudFront.GetInvalidation(ctx, &cloudfront.GetInvalidationInput{
+			DistributionId: distribution.Id,
+			Id:             id,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		details[aws.ToString(id)] = output.Invalidation
+
+		return output.Invalidation, nil
+	}
+
+	previous := result.State
+	result.State.InProgress = nil
+	result.CompletionsTracked = c.Store != nil
+
+	for i, invalidation := range invalidations {
+		id := aws.ToString(invalidation.Id)
+
+		switch aws.ToString(invalidation.Status) {
+		case StatusInProgress:
+			result.InProgress++
+			result.State.InProgress = append(result.State.InProgress, id)
+
+			detail, err := getInvalidation(invalidation.Id)
+			if err != nil {
+				return result, fmt.Errorf("failed to get invalidation detail: %w", err)
+			}
+
+			// In progress paths count towards the distribution's quotas.
+			if detail != nil {
+				for _, path := range detail.InvalidationBatch.Paths.Items {
+					if paths.IsWildcard(path) {
+						result.WildcardPathsInProgress++
+					} else {
+						result.PathsInProgress++
+					}
+				}
+			}
+		case StatusCompleted:
+			// Completed since the previous run, either because it was created
+			// since then or because it was in progress at the time.
+			if i < included || slices.Contains(previous.InProgress, id) {
+				result.Completed++
+				result.CompletionSeconds = append(result.CompletionSeconds, c.Window.End.Sub(aws.ToTime(invalidation.CreateTime)).Seconds())
+			}
+		}
+	}
+
+	invalidations = invalidations[:included]
+
+	month := c.Window.Month()
+
+	if result.State.Month != month {
+		result.State.Month = month
+		result.State.MonthPaths = 0
+	}
+
+	switch {
+	case len(invalidations) > 0:
+		// Invalidations are listed newest first, so the checkpoint is advanced
+		// from the oldest to end at the newest.
+		next := checkpoint
+
+		for i := len(invalidations) - 1; i >= 0; i-- {
+			next = next.Advance(aws.ToString(invalidations[i].Id), aws.ToTime(invalidations[i].CreateTime))
+		}
+
+		result.State.Checkpoint = next
+	case checkpoint.InvalidationID == "":
+		// Nothing was created before the end of the window, so the next
+		// run can start from there.
+		result.State.Checkpoint = state.Checkpoint{
+			CreateTime: c.Window.End,
+		}
+	}
+
+	for _, invalidation := range invalidations {
+		// Include Invalidation in count as the timeframe is acceptable.
+		result.Invalidations++
+
+		invalidationDetail, err := getInvalidation(invalidation.Id)
+		if err != nil {
+			return result, fmt.Errorf("failed to get invalidation detail: %w", err)
+		}
+
+		if invalidationDetail != nil {
+			batch := invalidationDetail.InvalidationBatch
+
+			result.Paths = result.Paths + float64(*batch.Paths.Quantity)
+
+			// Paths from the previous month are not counted towards this
+			// one, and that month's total is no longer published, so they
+			// are left out of both.
+			if billing.Month(aws.ToTime(invalidation.CreateTime)) == month {
+				result.MonthPaths = result.MonthPaths + float64(*batch.Paths.Quantity)
+			}
+
+			if len(c.Sources) > 0 {
+				if result.Sources == nil {
+					result.Sources = make(map[string]sourceResult)
+				}
+
+				source := c.Sources.Match(aws.ToString(batch.CallerReference))
+
+				counts := result.Sources[source]
+				counts.Invalidations++
+				counts.Paths = counts.Paths + float64(*batch.Paths.Quantity)
+				result.Sources[source] = counts
+			}
+
+			var fullPurge bool
+
+			for _, path := range batch.Paths.Items {
+				if result.PathGroups != nil {
+					result.PathGroups[c.PathGroups.Classify(path)]++
+				}
+
+				if paths.IsWildcard(path) {
+					result.WildcardPaths++
+				}
+
+				if paths.IsFullPurge(path) {
+					fullPurge = true
+				}
+			}
+
+			if fullPurge {
+				result.FullPurges++
+			}
+		}
+	}
+
+	result.State.MonthPaths = result.State.MonthPaths + result.MonthPaths
+
+	result.PathQuotaUtilisation = 100 * result.PathsInProgress / c.PathQuota
+	result.WildcardQuotaUtilisation = 100 * result.WildcardPathsInProgress / c.WildcardQuota
+
+	return result, nil
+}
+
+// tagDimensions returns a dimension for each of the configured tag keys
+// which the distribution is tagged with, in the order they are configured.
+func (c collector) tagDimensions(ctx context.Context, distribution cftypes.DistributionSummary) ([]types.Dimension, error) {
+	output, err := c.CloudFront.ListTagsForResource(ctx, &cloudfront.ListTagsForResourceInput{
+		Resource: distribution.ARN,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string)
+
+	if output.Tags != nil {
+		for _, tag := range output.Tags.Items {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+
+	var dimensions []types.Dimension
+
+	for _, key := range c.TagDimensions {
+		// CloudWatch does not accept empty dimension values.
+		if tags[key] == "" {
+			continue
+		}
+
+		dimensions = append(dimensions, types.Dimension{
+			Name:  aws.String(key),
+			Value: aws.String(tags[key]),
+		})
+	}
+
+	return dimensions, nil
+}
+
+// listDistributions returns every distribution in the account, following
+// NextMarker until the listing is no longer truncated.
+func listDistributions(ctx context.Context, clientCloudFront cloudfrontclient.ClientInterface) ([]cftypes.DistributionSummary, error) {
+	var distributions []cftypes.DistributionSummary
+
+	paginator := cloudfront.NewListDistributionsPaginator(clientCloudFront, &cloudfront.ListDistributionsInput{})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if page.DistributionList == nil {
+			break
+		}
+
+		distributions = append(distributions, page.DistributionList.Items...)
+	}
+
+	return distributions, nil
+}
+
+// listInvalidations returns the invalidations for a distribution which were
+// created before the given time, newest first. The invalidations the
+// checkpoint includes come first and their number is returned alongside.
+// CloudFront lists invalidations newest first, but in no particular order
+// within the second their create times are precise to. Listing continues past
+// the checkpoint until invalidations are older than the horizon, so that the
+// status of recent invalidations can be observed.
+func listInvalidations(ctx context.Context, clientCloudFront cloudfrontclient.ClientInterface, distributionID *string, checkpoint state.Checkpoint, before, horizon time.Time) ([]cftypes.InvalidationSummary, int, error) {
+	var included, others []cftypes.InvalidationSummary
+
+	paginator := cloudfront.NewListInvalidationsPaginator(clientCloudFront, &cloudfront.ListInvalidationsInput{
+		DistributionId: distributionID,
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if page.InvalidationList == nil {
+			break
+		}
+
+		for _, invalidation := range page.InvalidationList.Items {
+			createTime := aws.ToTime(invalidation.CreateTime)
+
+			// Created after the window ended, this will be counted by the next run.
+			if !createTime.Before(before) {
+				continue
+			}
+
+			if checkpoint.Includes(aws.ToString(invalidation.Id), createTime) {
+				included = append(included, invalidation)
+				continue
+			}
+
+			if createTime.Before(horizon) {
+				return append(included, others...), len(included), nil
+			}
+
+			others = append(others, invalidation)
+		}
+	}
+
+	return append(included, others...), len(included), nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/billing"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/state"
+)
+
+const (
+	// AccountStateKey is the key account wide state is stored against.
+	AccountStateKey = "account"
+)
+
+// accountStateKey for the account with the given ID, keeping the original
+// key for the account the Lambda runs in.
+func accountStateKey(accountID string) string {
+	if accountID == "" {
+		return AccountStateKey
+	}
+
+	return AccountStateKey + "/" + accountID
+}
+
+// billingResult holds the month to date billing for a distribution.
+type billingResult struct {
+	PathsMonthToDate float64
+	EstimatedCostUSD float64
+}
+
+// accountBilling accumulates the paths invalidated in the month of the
+// window across every distribution, and shares the estimated cost of
+// the account between distributions by the paths they invalidated.
+// The account state is returned to be stored once metrics are pushed.
+func accountBilling(ctx context.Context, store state.StoreInterface, accountID string, window Window, pricing billing.Pricing, results []distributionResult) (state.State, error) {
+	account, err := store.Get(ctx, accountStateKey(accountID))
+	if err != nil {
+		return account, fmt.Errorf("failed to get account state: %w", err)
+	}
+
+	month := window.Month()
+
+	if account.Month != month {
+		account.Month = month
+		account.MonthPaths = 0
+	}
+
+	for _, result := range results {
+		account.MonthPaths = account.MonthPaths + result.MonthPaths
+	}
+
+	cost := pricing.Cost(account.MonthPaths)
+
+	for i, result := range results {
+		results[i].Billing = &billingResult{
+			PathsMonthToDate: result.State.MonthPaths,
+		}
+
+		if account.MonthPaths > 0 {
+			results[i].Billing.EstimatedCostUSD = cost * result.State.MonthPaths / account.MonthPaths
+		}
+	}
+
+	return account, nil
+}
+
+// accountDatums returns the month to date billing metrics for the account.
+func accountDatums(account state.State, accountID string, pricing billing.Pricing, timestamp time.Time) []types.MetricDatum {
+	dimensions := accountDimensions(accountID)
+
+	return []types.MetricDatum{
+		newDatum(MetricInvalidationPathsMonthToDate, types.StandardUnitCount, account.MonthPaths, timestamp, dimensions),
+		newDatum(MetricInvalidationFreeTierRemaining, types.StandardUnitCount, pricing.FreeTierRemaining(account.MonthPaths), timestamp, dimensions),
+		newDatum(MetricInvalidationEstimatedCostUSD, types.StandardUnitNone, pricing.Cost(account.MonthPaths), timestamp, dimensions),
+	}
+}
+
+// accountDimensions identify the account metrics were collected from, or
+// nil for the account the Lambda runs in.
+func accountDimensions(accountID string) []types.Dimension {
+	if accountID == "" {
+		return nil
+	}
+
+	return []types.Dimension{
+		{
+			Name:  aws.String("AccountId"),
+			Value: aws.String(accountID),
+		},
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/metrics"
+)
+
+const (
+	// MetricInvalidationRequest counts invalidations created within the window.
+	MetricInvalidationRequest = "InvalidationRequest"
+	// MetricInvalidationPathCounter counts paths invalidated within the window.
+	MetricInvalidationPathCounter = "InvalidationPathCounter"
+	// MetricInvalidationWildcardPaths counts paths invalidated within the
+	// window which contain a wildcard.
+	MetricInvalidationWildcardPaths = "InvalidationWildcardPaths"
+	// MetricInvalidationFullPurge counts invalidations created within the
+	// window which invalidate every object in the distribution.
+	MetricInvalidationFullPurge = "InvalidationFullPurge"
+	// MetricInvalidationPathsMonthToDate counts paths invalidated since the
+	// start of the month.
+	MetricInvalidationPathsMonthToDate = "InvalidationPathsMonthToDate"
+	// MetricInvalidationFreeTierRemaining is how many more paths can be
+	// invalidated for free this month.
+	MetricInvalidationFreeTierRemaining = "InvalidationFreeTierRemaining"
+	// MetricInvalidationEstimatedCostUSD is the estimated cost of the paths
+	// invalidated since the start of the month.
+	MetricInvalidationEstimatedCostUSD = "InvalidationEstimatedCostUSD"
+	// MetricInvalidationsInProgress is the number of invalidations in progress
+	// at the end of the window.
+	MetricInvalidationsInProgress = "InvalidationsInProgress"
+	// MetricInvalidationsCompleted counts invalidations which completed
+	// within the window.
+	MetricInvalidationsCompleted = "InvalidationsCompleted"
+	// MetricInvalidationPathsInProgress is the number of paths, excluding
+	// wildcards, in progress at the end of the window.
+	MetricInvalidationPathsInProgress = "InvalidationPathsInProgress"
+	// MetricInvalidationWildcardPathsInProgress is the number of wildcard
+	// paths in progress at the end of the window.
+	MetricInvalidationWildcardPathsInProgress = "InvalidationWildcardPathsInProgress"
+	// MetricInvalidationPathQuotaUtilisation is the percentage of the in
+	// progress path quota used at the end of the window.
+	MetricInvalidationPathQuotaUtilisation = "InvalidationPathQuotaUtilisation"
+	// MetricInvalidationWildcardQuotaUtilisation is the percentage of the in
+	// progress wildcard path quota used at the end of the window.
+	MetricInvalidationWildcardQuotaUtilisation = "InvalidationWildcardQuotaUtilisation"
+	// MetricInvalidationCompletionSeconds is how long invalidations which
+	// completed within the window took to complete.
+	MetricInvalidationCompletionSeconds = "InvalidationCompletionSeconds"
+)
+
+// counterMetrics are counted within each window, as opposed to measuring
+// a value at the end of it, so they can be summed across windows.
+var counterMetrics = []string{
+	MetricInvalidationRequest,
+	MetricInvalidationPathCounter,
+	MetricInvalidationWildcardPaths,
+	MetricInvalidationFullPurge,
+	MetricInvalidationsCompleted,
+}
+
+// datums returns the metrics for a distribution, timestamped with the given time.
+func (r distributionResult) datums(timestamp time.Time) []types.MetricDatum {
+	dimensions := r.dimensions()
+
+	data := []types.MetricDatum{
+		newDatum(MetricInvalidationRequest, types.StandardUnitCount, r.Invalidations, timestamp, dimensions),
+		newDatum(MetricInvalidationPathCounter, types.StandardUnitCount, r.Paths, timestamp, dimensions),
+		newDatum(MetricInvalidationWildcardPaths, types.StandardUnitCount, r.WildcardPaths, timestamp, dimensions),
+		newDatum(MetricInvalidationFullPurge, types.StandardUnitCount, r.FullPurges, timestamp, dimensions),
+		newDatum(MetricInvalidationsInProgress, types.StandardUnitCount, r.InProgress, timestamp, dimensions),
+		newDatum(MetricInvalidationPathsInProgress, types.StandardUnitCount, r.PathsInProgress, timestamp, dimensions),
+		newDatum(MetricInvalidationWildcardPathsInProgress, types.StandardUnitCount, r.WildcardPathsInProgress, timestamp, dimensions),
+		newDatum(MetricInvalidationPathQuotaUtilisation, types.StandardUnitPercent, r.PathQuotaUtilisation, timestamp, dimensions),
+		newDatum(MetricInvalidationWildcardQuotaUtilisation, types.StandardUnitPercent, r.WildcardQuotaUtilisation, timestamp, dimensions),
+	}
+
+	// Without state, invalidations which were created in an earlier window
+	// and completed in this one are missed, so the counts would be low.
+	if r.CompletionsTracked {
+		data = append(data, newDatum(MetricInvalidationsCompleted, types.StandardUnitCount, r.Completed, timestamp, dimensions))
+	}
+
+	if r.Billing != nil {
+		data = append(data,
+			newDatum(MetricInvalidationPathsMonthToDate, types.StandardUnitCount, r.Billing.PathsMonthToDate, timestamp, dimensions),
+			newDatum(MetricInvalidationEstimatedCostUSD, types.StandardUnitNone, r.Billing.EstimatedCostUSD, timestamp, dimensions),
+		)
+	}
+
+	for _, group := range slices.Sorted(maps.Keys(r.PathGroups)) {
+		data = append(data, newDatum(MetricInvalidationPathCounter, types.StandardUnitCount, r.PathGroups[group], timestamp, append(slices.Clone(dimensions), types.Dimension{
+			Name:  aws.String("PathGroup"),
+			Value: aws.String(group),
+		})))
+	}
+
+	for _, source := range slices.Sorted(maps.Keys(r.Sources)) {
+		sourceDimensions := append(slices.Clone(dimensions), types.Dimension{
+			Name:  aws.String("Source"),
+			Value: aws.String(source),
+		})
+
+		data = append(data,
+			newDatum(MetricInvalidationRequest, types.StandardUnitCount, r.Sources[source].Invalidations, timestamp, sourceDimensions),
+			newDatum(MetricInvalidationPathCounter, types.StandardUnitCount, r.Sources[source].Paths, timestamp, sourceDimensions),
+		)
+	}
+
+	// Each completion is published as a value of a single datum so that
+	// CloudWatch can report percentiles. Nothing is published when no
+	// invalidation completed, as a zero would skew those percentiles.
+	if !r.CompletionsTracked {
+		return data
+	}
+
+	for values := range slices.Chunk(r.CompletionSeconds, metrics.AwsValuesLimit) {
+		data = append(data, types.MetricDatum{
+			MetricName: aws.String(MetricInvalidationCompletionSeconds),
+			Unit:       types.StandardUnitSeconds,
+			Values:     values,
+			Timestamp:  aws.Time(timestamp),
+			Dimensions: dimensions,
+		})
+	}
+
+	return data
+}
+
+// newDatum for a metric.
+func newDatum(name string, unit types.StandardUnit, value float64, timestamp time.Time, dimensions []types.Dimension) types.MetricDatum {
+	return types.MetricDatum{
+		MetricName: aws.String(name),
+		Unit:       unit,
+		Value:      aws.Float64(value),
+		Timestamp:  aws.Time(timestamp),
+		Dimensions: dimensions,
+	}
+}
+
+// dimensions which are added to every metric for the distribution.
+func (r distributionResult) dimensions() []types.Dimension {
+	return append([]types.Dimension{
+		{
+			Name:  aws.String("Distribution"),
+			Value: aws.String(r.DistributionID),
+		},
+	}, r.Dimensions...)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/dashboard"
+)
+
+// accountGraphs are rendered once for the metrics published for the account.
+var accountGraphs = []dashboard.Graph{
+	{
+		Title:   "Paths this month",
+		Stat:    "Maximum",
+		Metrics: []string{MetricInvalidationPathsMonthToDate, MetricInvalidationFreeTierRemaining},
+	},
+	{
+		Title:   "Estimated cost (USD)",
+		Stat:    "Maximum",
+		Metrics: []string{MetricInvalidationEstimatedCostUSD},
+	},
+}
+
+// distributionGraphs are rendered for the metrics published for each distribution.
+var distributionGraphs = []dashboard.Graph{
+	{
+		Title:   "Invalidations",
+		Stat:    "Sum",
+		Metrics: []string{MetricInvalidationRequest},
+	},
+	{
+		Title:   "Paths",
+		Stat:    "Sum",
+		Metrics: []string{MetricInvalidationPathCounter, MetricInvalidationWildcardPaths, MetricInvalidationFullPurge},
+	},
+	{
+		Title:   "In progress",
+		Stat:    "Maximum",
+		Metrics: []string{MetricInvalidationsInProgress, MetricInvalidationPathsInProgress, MetricInvalidationWildcardPathsInProgress},
+	},
+	{
+		Title:   "Quota utilisation (%)",
+		Stat:    "Maximum",
+		Metrics: []string{MetricInvalidationPathQuotaUtilisation, MetricInvalidationWildcardQuotaUtilisation},
+	},
+}
+
+// distributionStateGraphs are rendered for the completion and month to date
+// billing metrics published for each distribution, which need state.
+var distributionStateGraphs = []dashboard.Graph{
+	{
+		Title:   "Completed",
+		Stat:    "Sum",
+		Metrics: []string{MetricInvalidationsCompleted},
+	},
+	{
+		Title:   "Completion time (p90 seconds)",
+		Stat:    "p90",
+		Metrics: []string{MetricInvalidationCompletionSeconds},
+	},
+	{
+		Title:   "Paths this month",
+		Stat:    "Maximum",
+		Metrics: []string{MetricInvalidationPathsMonthToDate},
+	},
+	{
+		Title:   "Estimated cost (USD)",
+		Stat:    "Maximum",
+		Metrics: []string{MetricInvalidationEstimatedCostUSD},
+	},
+}
@@ -0,0 +1,222 @@
+package alarms
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+
+	cloudwatchclient "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/cloudwatch"
+)
+
+const (
+	// DefaultPrefix is prepended to the name of every alarm which is managed.
+	DefaultPrefix = "CloudFrontInvalidation-"
+	// DeleteLimit is the most alarms CloudWatch deletes in a single request.
+	DeleteLimit = 100
+)
+
+// Template for an alarm which is created for every distribution.
+type Template struct {
+	// Name of the alarm, which is prefixed with the distribution ID.
+	Name               string   `json:"name"`
+	Description        string   `json:"description"`
+	MetricName         string   `json:"metric"`
+	Statistic          string   `json:"statistic"`
+	Period             int32    `json:"period"`
+	EvaluationPeriods  int32    `json:"evaluationPeriods"`
+	Threshold          float64  `json:"threshold"`
+	ComparisonOperator string   `json:"comparisonOperator"`
+	TreatMissingData   string   `json:"treatMissingData"`
+	AlarmActions       []string `json:"alarmActions"`
+	OKActions          []string `json:"okActions"`
+}
+
+// ParseTemplates from a JSON array, filling in defaults for anything
+// which is not set.
+func ParseTemplates(value string) ([]Template, error) {
+	var templates []Template
+
+	if value == "" {
+		return templates, nil
+	}
+
+	err := json.Unmarshal([]byte(value), &templates)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode templates: %w", err)
+	}
+
+	for i, template := range templates {
+		if template.Name == "" || template.MetricName == "" {
+			return nil, fmt.Errorf("template requires a name and metric")
+		}
+
+		if template.Statistic == "" {
+			templates[i].Statistic = string(types.StatisticSum)
+		}
+
+		if template.Period == 0 {
+			templates[i].Period = 300
+		}
+
+		if template.EvaluationPeriods == 0 {
+			templates[i].EvaluationPeriods = 1
+		}
+
+		if template.ComparisonOperator == "" {
+			templates[i].ComparisonOperator = string(types.ComparisonOperatorGreaterThanThreshold)
+		}
+
+		if template.TreatMissingData == "" {
+			templates[i].TreatMissingData = "notBreaching"
+		}
+	}
+
+	return templates, nil
+}
+
+// Target which alarms are created for.
+type Target struct {
+	// Name the alarms for the target are prefixed with.
+	Name string
+	// Dimensions of the metrics published for the target.
+	Dimensions []types.Dimension
+}
+
+// Reconciler manages alarms for every target from templates.
+type Reconciler struct {
+	CloudWatch cloudwatchclient.ClientInterface
+	Namespace  string
+	Prefix     string
+	Templates  []Template
+}
+
+// New reconciler for managing alarms.
+func New(cloudwatch cloudwatchclient.ClientInterface, namespace, prefix string, templates []Template) (*Reconciler, error) {
+	return &Reconciler{
+		CloudWatch: cloudwatch,
+		Namespace:  namespace,
+		Prefix:     prefix,
+		Templates:  templates,
+	}, nil
+}
+
+// Reconcile creates or updates an alarm for every target and template, and
+// deletes managed alarms which no longer have a target or template.
+func (r *Reconciler) Reconcile(ctx context.Context, targets []Target) error {
+	existing, err := r.existing(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to describe alarms: %w", err)
+	}
+
+	desired := make(map[string]bool)
+
+	for _, target := range targets {
+		for _, template := range r.Templates {
+			input := r.input(target, template)
+
+			name := aws.ToString(input.AlarmName)
+			desired[name] = true
+
+			// Only put alarms which have changed to keep API calls down.
+			if alarm, ok := existing[name]; ok && matches(alarm, input) {
+				continue
+			}
+
+			_, err := r.CloudWatch.PutMetricAlarm(ctx, input)
+			if err != nil {
+				return fmt.Errorf("failed to put alarm: %s: %w", name, err)
+			}
+		}
+	}
+
+	var stale []string
+
+	for name := range existing {
+		if !desired[name] {
+			stale = append(stale, name)
+		}
+	}
+
+	slices.Sort(stale)
+
+	for names := range slices.Chunk(stale, DeleteLimit) {
+		_, err := r.CloudWatch.DeleteAlarms(ctx, &cloudwatch.DeleteAlarmsInput{
+			AlarmNames: names,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete alarms: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// existing alarms which are managed, keyed by name.
+func (r *Reconciler) existing(ctx context.Context) (map[string]types.MetricAlarm, error) {
+	alarms := make(map[string]types.MetricAlarm)
+
+	paginator := cloudwatch.NewDescribeAlarmsPaginator(r.CloudWatch, &cloudwatch.DescribeAlarmsInput{
+		AlarmNamePrefix: aws.String(r.Prefix),
+		AlarmTypes:      []types.AlarmType{types.AlarmTypeMetricAlarm},
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, alarm := range page.MetricAlarms {
+			alarms[aws.ToString(alarm.AlarmName)] = alarm
+		}
+	}
+
+	return alarms, nil
+}
+
+// input for putting the alarm for a target from a template.
+func (r *Reconciler) input(target Target, template Template) *cloudwatch.PutMetricAlarmInput {
+	input := &cloudwatch.PutMetricAlarmInput{
+		AlarmName:          aws.String(r.Prefix + target.Name + "-" + template.Name),
+		AlarmActions:       template.AlarmActions,
+		OKActions:          template.OKActions,
+		ComparisonOperator: types.ComparisonOperator(template.ComparisonOperator),
+		Dimensions:         target.Dimensions,
+		EvaluationPeriods:  aws.Int32(template.EvaluationPeriods),
+		MetricName:         aws.String(template.MetricName),
+		Namespace:          aws.String(r.Namespace),
+		Period:             aws.Int32(template.Period),
+		Statistic:          types.Statistic(template.Statistic),
+		Threshold:          aws.Float64(template.Threshold),
+		TreatMissingData:   aws.String(template.TreatMissingData),
+	}
+
+	if template.Description != "" {
+		input.AlarmDescription = aws.String(template.Description)
+	}
+
+	return input
+}
+
+// matches reports whether an existing alarm is configured as the input.
+func matches(alarm types.MetricAlarm, input *cloudwatch.PutMetricAlarmInput) bool {
+	return aws.ToString(alarm.AlarmDescription) == aws.ToString(input.AlarmDescription) &&
+		slices.Equal(alarm.AlarmActions, input.AlarmActions) &&
+		slices.Equal(alarm.OKActions, input.OKActions) &&
+		alarm.ComparisonOperator == input.ComparisonOperator &&
+		slices.EqualFunc(alarm.Dimensions, input.Dimensions, func(a, b types.Dimension) bool {
+			return aws.ToString(a.Name) == aws.ToString(b.Name) && aws.ToString(a.Value) == aws.ToString(b.Value)
+		}) &&
+		aws.ToInt32(alarm.EvaluationPeriods) == aws.ToInt32(input.EvaluationPeriods) &&
+		aws.ToString(alarm.MetricName) == aws.ToString(input.MetricName) &&
+		aws.ToString(alarm.Namespace) == aws.ToString(input.Namespace) &&
+		aws.ToInt32(alarm.Period) == aws.ToInt32(input.Period) &&
+		alarm.Statistic == input.Statistic &&
+		aws.ToFloat64(alarm.Threshold) == aws.ToFloat64(input.Threshold) &&
+		aws.ToString(alarm.TreatMissingData) == aws.ToString(input.TreatMissingData)
+}
@@ -0,0 +1,98 @@
+package alarms
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/stretchr/testify/assert"
+
+	cloudwatchclient "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/cloudwatch"
+)
+
+func TestParseTemplates(t *testing.T) {
+	templates, err := ParseTemplates(`[{"name": "Paths", "metric": "InvalidationPathCounter", "threshold": 100}]`)
+	assert.NoError(t, err)
+	assert.Equal(t, []Template{
+		{
+			Name:               "Paths",
+			MetricName:         "InvalidationPathCounter",
+			Statistic:          "Sum",
+			Period:             300,
+			EvaluationPeriods:  1,
+			Threshold:          100,
+			ComparisonOperator: "GreaterThanThreshold",
+			TreatMissingData:   "notBreaching",
+		},
+	}, templates)
+
+	_, err = ParseTemplates(`[{"name": "Paths"}]`)
+	assert.Error(t, err)
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.TODO()
+
+	cw := &cloudwatchclient.MockClient{
+		Alarms: map[string]types.MetricAlarm{
+			// Not managed, should be left alone.
+			"Unrelated": {AlarmName: aws.String("Unrelated")},
+		},
+	}
+
+	templates, err := ParseTemplates(`[
+		{"name": "Paths", "metric": "InvalidationPathCounter", "threshold": 100},
+		{"name": "FullPurge", "metric": "InvalidationFullPurge", "threshold": 0}
+	]`)
+	assert.NoError(t, err)
+
+	reconciler, err := New(cw, "Skpr/CloudFront", DefaultPrefix, templates)
+	assert.NoError(t, err)
+
+	target := func(id string) Target {
+		return Target{
+			Name: id,
+			Dimensions: []types.Dimension{
+				{Name: aws.String("Distribution"), Value: aws.String(id)},
+			},
+		}
+	}
+
+	err = reconciler.Reconcile(ctx, []Target{target("dist-a"), target("dist-b")})
+	assert.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{
+		"CloudFrontInvalidation-dist-a-Paths",
+		"CloudFrontInvalidation-dist-a-FullPurge",
+		"CloudFrontInvalidation-dist-b-Paths",
+		"CloudFrontInvalidation-dist-b-FullPurge",
+	}, cw.AlarmPuts)
+
+	// Nothing has changed, so nothing should be put again.
+	cw.AlarmPuts = nil
+
+	err = reconciler.Reconcile(ctx, []Target{target("dist-a"), target("dist-b")})
+	assert.NoError(t, err)
+	assert.Empty(t, cw.AlarmPuts)
+
+	// A changed threshold is updated and alarms for removed distributions are deleted.
+	reconciler.Templates[0].Threshold = 50
+
+	err = reconciler.Reconcile(ctx, []Target{target("dist-a")})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"CloudFrontInvalidation-dist-a-Paths"}, cw.AlarmPuts)
+	assert.Equal(t, float64(50), aws.ToFloat64(cw.Alarms["CloudFrontInvalidation-dist-a-Paths"].Threshold))
+
+	var names []string
+
+	for name := range cw.Alarms {
+		names = append(names, name)
+	}
+
+	assert.ElementsMatch(t, []string{
+		"Unrelated",
+		"CloudFrontInvalidation-dist-a-Paths",
+		"CloudFrontInvalidation-dist-a-FullPurge",
+	}, names)
+}
@@ -2,6 +2,7 @@ package cloudfront
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -17,10 +18,24 @@ type ClientInterface interface {
 	GetInvalidation(ctx context.Context, params *cloudfront.GetInvalidationInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetInvalidationOutput, error)
 	ListDistributions(ctx context.Context, params *cloudfront.ListDistributionsInput, optFns ...func(*cloudfront.Options)) (*cloudfront.ListDistributionsOutput, error)
 	ListInvalidations(ctx context.Context, params *cloudfront.ListInvalidationsInput, optFns ...func(*cloudfront.Options)) (*cloudfront.ListInvalidationsOutput, error)
+	ListTagsForResource(ctx context.Context, params *cloudfront.ListTagsForResourceInput, optFns ...func(*cloudfront.Options)) (*cloudfront.ListTagsForResourceOutput, error)
 }
 
 // MockClient for testing.
-type MockClient struct{}
+type MockClient struct {
+	// DistributionPages are served in order by ListDistributions.
+	// When empty a single page with one distribution is served.
+	DistributionPages [][]types.DistributionSummary
+	// InvalidationPages are served in order by ListInvalidations, keyed
+	// by distribution ID. When empty a single page with one invalidation
+	// is served.
+	InvalidationPages map[string][][]types.InvalidationSummary
+	// Invalidations are served by GetInvalidation, keyed by invalidation ID.
+	// When missing an invalidation with three paths is served.
+	Invalidations map[string]types.Invalidation
+	// Tags are served by ListTagsForResource, keyed by resource ARN.
+	Tags map[string][]types.Tag
+}
 
 // GetDistribution mock function.
 func (c MockClient) GetDistribution(ctx context.Context, params *cloudfront.GetDistributionInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetDistributionOutput, error) {
@@ -33,6 +48,13 @@ func (c MockClient) GetDistribution(ctx context.Context, params *cloudfront.GetD
 
 // GetInvalidation mock function.
 func (c MockClient) GetInvalidation(ctx context.Context, params *cloudfront.GetInvalidationInput, optFns ...func(*cloudfront.Options)) (*cloudfront.GetInvalidationOutput, error) {
+	if invalidation, ok := c.Invalidations[aws.ToString(params.Id)]; ok {
+		return &cloudfront.GetInvalidationOutput{
+			Invalidation:   &invalidation,
+			ResultMetadata: middleware.Metadata{},
+		}, nil
+	}
+
 	return &cloudfront.GetInvalidationOutput{
 		Invalidation: &types.Invalidation{
 			CreateTime: aws.Time(time.Now()),
@@ -55,13 +77,24 @@ func (c MockClient) GetInvalidation(ctx context.Context, params *cloudfront.GetI
 
 // ListDistributions mock function.
 func (c MockClient) ListDistributions(ctx context.Context, params *cloudfront.ListDistributionsInput, optFns ...func(*cloudfront.Options)) (*cloudfront.ListDistributionsOutput, error) {
-	return &cloudfront.ListDistributionsOutput{
-		DistributionList: &types.DistributionList{
-			Items: []types.DistributionSummary{
+	pages := c.DistributionPages
+	if len(pages) == 0 {
+		pages = [][]types.DistributionSummary{
+			{
 				{
 					Id: aws.String("test-distribution-id"),
 				},
 			},
+		}
+	}
+
+	page, next := mockPage(params.Marker, len(pages))
+
+	return &cloudfront.ListDistributionsOutput{
+		DistributionList: &types.DistributionList{
+			Items:       pages[page],
+			IsTruncated: aws.Bool(next != nil),
+			NextMarker:  next,
 		},
 		ResultMetadata: middleware.Metadata{},
 	}, nil
@@ -69,16 +102,51 @@ func (c MockClient) ListDistributions(ctx context.Context, params *cloudfront.Li
 
 // ListInvalidations mock function.
 func (c MockClient) ListInvalidations(ctx context.Context, params *cloudfront.ListInvalidationsInput, optFns ...func(*cloudfront.Options)) (*cloudfront.ListInvalidationsOutput, error) {
-	return &cloudfront.ListInvalidationsOutput{
-		InvalidationList: &types.InvalidationList{
-			Items: []types.InvalidationSummary{
+	pages := c.InvalidationPages[aws.ToString(params.DistributionId)]
+	if len(pages) == 0 {
+		pages = [][]types.InvalidationSummary{
+			{
 				{
 					Id:         aws.String("test-invalidation-id"),
 					Status:     aws.String("Completed"),
 					CreateTime: aws.Time(time.Now()),
 				},
 			},
+		}
+	}
+
+	page, next := mockPage(params.Marker, len(pages))
+
+	return &cloudfront.ListInvalidationsOutput{
+		InvalidationList: &types.InvalidationList{
+			Items:       pages[page],
+			IsTruncated: aws.Bool(next != nil),
+			NextMarker:  next,
 		},
 		ResultMetadata: middleware.Metadata{},
 	}, nil
 }
+
+// ListTagsForResource mock function.
+func (c MockClient) ListTagsForResource(ctx context.Context, params *cloudfront.ListTagsForResourceInput, optFns ...func(*cloudfront.Options)) (*cloudfront.ListTagsForResourceOutput, error) {
+	return &cloudfront.ListTagsForResourceOutput{
+		Tags: &types.Tags{
+			Items: c.Tags[aws.ToString(params.Resource)],
+		},
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+// mockPage resolves a marker to a page index and the marker for the page after it.
+func mockPage(marker *string, total int) (int, *string) {
+	page, _ := strconv.Atoi(aws.ToString(marker))
+	if page < 0 || page >= total {
+		page = 0
+	}
+
+	if page+1 < total {
+		return page, aws.String(strconv.Itoa(page + 1))
+	}
+
+	return page, nil
+}
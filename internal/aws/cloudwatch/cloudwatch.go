@@ -2,7 +2,10 @@ package cloudwatch
 
 import (
 	"context"
+	"sort"
+	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 	"github.com/aws/smithy-go/middleware"
@@ -10,16 +13,152 @@ import (
 
 // ClientInterface is a mock cloudwatch interface.
 type ClientInterface interface {
+	DeleteAlarms(ctx context.Context, params *cloudwatch.DeleteAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DeleteAlarmsOutput, error)
+	DeleteDashboards(ctx context.Context, params *cloudwatch.DeleteDashboardsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DeleteDashboardsOutput, error)
+	DescribeAlarms(ctx context.Context, params *cloudwatch.DescribeAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DescribeAlarmsOutput, error)
+	GetDashboard(ctx context.Context, params *cloudwatch.GetDashboardInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetDashboardOutput, error)
+	PutDashboard(ctx context.Context, params *cloudwatch.PutDashboardInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutDashboardOutput, error)
+	PutMetricAlarm(ctx context.Context, params *cloudwatch.PutMetricAlarmInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricAlarmOutput, error)
 	PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
 }
 
 // MockClient is a mock cloudwatch client.
 type MockClient struct {
 	MetricData []types.MetricDatum
+	// PutMetricDataErrors are returned by PutMetricData in order, one for
+	// each call, before it starts to succeed. Nil entries succeed.
+	PutMetricDataErrors []error
+	// PutMetricDataCalls counts the calls to PutMetricData.
+	PutMetricDataCalls int
+	// Alarms which have been put, keyed by name.
+	Alarms map[string]types.MetricAlarm
+	// AlarmPuts are the names of alarms in the order they were put.
+	AlarmPuts []string
+	// Dashboards which have been put, keyed by name.
+	Dashboards map[string]string
+	// DashboardPuts are the names of dashboards in the order they were put.
+	DashboardPuts []string
+}
+
+// DeleteAlarms mock function.
+func (c *MockClient) DeleteAlarms(ctx context.Context, params *cloudwatch.DeleteAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DeleteAlarmsOutput, error) {
+	for _, name := range params.AlarmNames {
+		delete(c.Alarms, name)
+	}
+
+	return &cloudwatch.DeleteAlarmsOutput{
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+// DescribeAlarms mock function.
+func (c *MockClient) DescribeAlarms(ctx context.Context, params *cloudwatch.DescribeAlarmsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DescribeAlarmsOutput, error) {
+	var alarms []types.MetricAlarm
+
+	for name, alarm := range c.Alarms {
+		if strings.HasPrefix(name, aws.ToString(params.AlarmNamePrefix)) {
+			alarms = append(alarms, alarm)
+		}
+	}
+
+	sort.Slice(alarms, func(i, j int) bool {
+		return aws.ToString(alarms[i].AlarmName) < aws.ToString(alarms[j].AlarmName)
+	})
+
+	return &cloudwatch.DescribeAlarmsOutput{
+		MetricAlarms:   alarms,
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+// DeleteDashboards mock function.
+func (c *MockClient) DeleteDashboards(ctx context.Context, params *cloudwatch.DeleteDashboardsInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.DeleteDashboardsOutput, error) {
+	for _, name := range params.DashboardNames {
+		delete(c.Dashboards, name)
+	}
+
+	return &cloudwatch.DeleteDashboardsOutput{
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+// GetDashboard mock function.
+func (c *MockClient) GetDashboard(ctx context.Context, params *cloudwatch.GetDashboardInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.GetDashboardOutput, error) {
+	body, ok := c.Dashboards[aws.ToString(params.DashboardName)]
+	if !ok {
+		return nil, &types.ResourceNotFound{
+			Message: aws.String("Dashboard does not exist"),
+		}
+	}
+
+	return &cloudwatch.GetDashboardOutput{
+		DashboardName:  params.DashboardName,
+		DashboardBody:  aws.String(body),
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+// PutDashboard mock function.
+func (c *MockClient) PutDashboard(ctx context.Context, params *cloudwatch.PutDashboardInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutDashboardOutput, error) {
+	if c.Dashboards == nil {
+		c.Dashboards = make(map[string]string)
+	}
+
+	name := aws.ToString(params.DashboardName)
+
+	c.Dashboards[name] = aws.ToString(params.DashboardBody)
+	c.DashboardPuts = append(c.DashboardPuts, name)
+
+	return &cloudwatch.PutDashboardOutput{
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+// PutMetricAlarm mock function.
+func (c *MockClient) PutMetricAlarm(ctx context.Context, params *cloudwatch.PutMetricAlarmInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricAlarmOutput, error) {
+	if c.Alarms == nil {
+		c.Alarms = make(map[string]types.MetricAlarm)
+	}
+
+	name := aws.ToString(params.AlarmName)
+
+	c.Alarms[name] = types.MetricAlarm{
+		AlarmName:          params.AlarmName,
+		AlarmDescription:   params.AlarmDescription,
+		AlarmActions:       params.AlarmActions,
+		OKActions:          params.OKActions,
+		ComparisonOperator: params.ComparisonOperator,
+		Dimensions:         params.Dimensions,
+		EvaluationPeriods:  params.EvaluationPeriods,
+		MetricName:         params.MetricName,
+		Namespace:          params.Namespace,
+		Period:             params.Period,
+		Statistic:          params.Statistic,
+		Threshold:          params.Threshold,
+		TreatMissingData:   params.TreatMissingData,
+	}
+
+	c.AlarmPuts = append(c.AlarmPuts, name)
+
+	return &cloudwatch.PutMetricAlarmOutput{
+		ResultMetadata: middleware.Metadata{},
+	}, nil
 }
 
 // PutMetricData mock function.
 func (c *MockClient) PutMetricData(ctx context.Context, params *cloudwatch.PutMetricDataInput, optFns ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error) {
+	c.PutMetricDataCalls++
+
+	// Inject faults before storing anything.
+	if len(c.PutMetricDataErrors) > 0 {
+		err := c.PutMetricDataErrors[0]
+		c.PutMetricDataErrors = c.PutMetricDataErrors[1:]
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Store the metrics for later.
 	c.MetricData = append(c.MetricData, params.MetricData...)
 
@@ -0,0 +1,68 @@
+package dynamodb
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// ClientInterface is a mock dynamodb interface.
+type ClientInterface interface {
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+}
+
+// MockClient is a mock dynamodb client which stores items in memory,
+// keyed by table and the string value of the given key attribute.
+type MockClient struct {
+	mu sync.Mutex
+	// KeyAttribute is the name of the partition key for every table.
+	KeyAttribute string
+	Items        map[string]map[string]map[string]types.AttributeValue
+}
+
+// GetItem mock function.
+func (c *MockClient) GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return &dynamodb.GetItemOutput{
+		Item:           c.Items[aws.ToString(params.TableName)][c.key(params.Key)],
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+// PutItem mock function.
+func (c *MockClient) PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Items == nil {
+		c.Items = make(map[string]map[string]map[string]types.AttributeValue)
+	}
+
+	table := aws.ToString(params.TableName)
+
+	if c.Items[table] == nil {
+		c.Items[table] = make(map[string]map[string]types.AttributeValue)
+	}
+
+	c.Items[table][c.key(params.Item)] = params.Item
+
+	return &dynamodb.PutItemOutput{
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+// key returns the string value of the partition key attribute.
+func (c *MockClient) key(item map[string]types.AttributeValue) string {
+	if value, ok := item[c.KeyAttribute].(*types.AttributeValueMemberS); ok {
+		return value.Value
+	}
+
+	return ""
+}
@@ -0,0 +1,110 @@
+package organizations
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// ClientInterface is a mock organizations client.
+type ClientInterface interface {
+	ListAccounts(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error)
+	ListAccountsForParent(ctx context.Context, params *organizations.ListAccountsForParentInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsForParentOutput, error)
+	ListOrganizationalUnitsForParent(ctx context.Context, params *organizations.ListOrganizationalUnitsForParentInput, optFns ...func(*organizations.Options)) (*organizations.ListOrganizationalUnitsForParentOutput, error)
+	ListTagsForResource(ctx context.Context, params *organizations.ListTagsForResourceInput, optFns ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error)
+}
+
+// MockClient for testing.
+type MockClient struct {
+	// AccountPages are served in order by ListAccounts.
+	AccountPages [][]types.Account
+	// Children are the IDs of the accounts directly within a parent, keyed
+	// by parent ID, served by ListAccountsForParent.
+	Children map[string][]string
+	// Units are the IDs of the organizational units directly within a
+	// parent, keyed by parent ID, served by ListOrganizationalUnitsForParent.
+	Units map[string][]string
+	// Tags are served by ListTagsForResource, keyed by resource ID.
+	Tags map[string][]types.Tag
+}
+
+// ListAccounts mock function.
+func (c MockClient) ListAccounts(ctx context.Context, params *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+	if len(c.AccountPages) == 0 {
+		return &organizations.ListAccountsOutput{
+			ResultMetadata: middleware.Metadata{},
+		}, nil
+	}
+
+	page, next := mockPage(params.NextToken, len(c.AccountPages))
+
+	return &organizations.ListAccountsOutput{
+		Accounts:       c.AccountPages[page],
+		NextToken:      next,
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+// ListAccountsForParent mock function, which returns every account within
+// the parent in a single page.
+func (c MockClient) ListAccountsForParent(ctx context.Context, params *organizations.ListAccountsForParentInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsForParentOutput, error) {
+	var accounts []types.Account
+
+	for _, id := range c.Children[aws.ToString(params.ParentId)] {
+		for _, page := range c.AccountPages {
+			for _, account := range page {
+				if aws.ToString(account.Id) == id {
+					accounts = append(accounts, account)
+				}
+			}
+		}
+	}
+
+	return &organizations.ListAccountsForParentOutput{
+		Accounts:       accounts,
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+// ListOrganizationalUnitsForParent mock function, which returns every
+// organizational unit within the parent in a single page.
+func (c MockClient) ListOrganizationalUnitsForParent(ctx context.Context, params *organizations.ListOrganizationalUnitsForParentInput, optFns ...func(*organizations.Options)) (*organizations.ListOrganizationalUnitsForParentOutput, error) {
+	var units []types.OrganizationalUnit
+
+	for _, id := range c.Units[aws.ToString(params.ParentId)] {
+		units = append(units, types.OrganizationalUnit{
+			Id: aws.String(id),
+		})
+	}
+
+	return &organizations.ListOrganizationalUnitsForParentOutput{
+		OrganizationalUnits: units,
+		ResultMetadata:      middleware.Metadata{},
+	}, nil
+}
+
+// ListTagsForResource mock function.
+func (c MockClient) ListTagsForResource(ctx context.Context, params *organizations.ListTagsForResourceInput, optFns ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error) {
+	return &organizations.ListTagsForResourceOutput{
+		Tags:           c.Tags[aws.ToString(params.ResourceId)],
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+// mockPage resolves a token to a page index and the token for the page after it.
+func mockPage(token *string, total int) (int, *string) {
+	page, _ := strconv.Atoi(aws.ToString(token))
+	if page < 0 || page >= total {
+		page = 0
+	}
+
+	if page+1 < total {
+		return page, aws.String(strconv.Itoa(page + 1))
+	}
+
+	return page, nil
+}
@@ -0,0 +1,112 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// ClientInterface is a mock s3 interface.
+type ClientInterface interface {
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+}
+
+// MockClient is a mock s3 client which stores objects in memory, keyed by
+// bucket and key.
+type MockClient struct {
+	mu      sync.Mutex
+	Objects map[string]map[string][]byte
+}
+
+// DeleteObject mock function.
+func (c *MockClient) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.Objects[aws.ToString(params.Bucket)], aws.ToString(params.Key))
+
+	return &s3.DeleteObjectOutput{
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+// GetObject mock function.
+func (c *MockClient) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	body, ok := c.Objects[aws.ToString(params.Bucket)][aws.ToString(params.Key)]
+	if !ok {
+		return nil, &types.NoSuchKey{
+			Message: aws.String("The specified key does not exist."),
+		}
+	}
+
+	return &s3.GetObjectOutput{
+		Body:           io.NopCloser(bytes.NewReader(body)),
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+// ListObjectsV2 mock function, which returns every matching object in a
+// single page.
+func (c *MockClient) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var contents []types.Object
+
+	for key := range c.Objects[aws.ToString(params.Bucket)] {
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			contents = append(contents, types.Object{Key: aws.String(key)})
+		}
+	}
+
+	sort.Slice(contents, func(i, j int) bool {
+		return aws.ToString(contents[i].Key) < aws.ToString(contents[j].Key)
+	})
+
+	return &s3.ListObjectsV2Output{
+		Contents:       contents,
+		IsTruncated:    aws.Bool(false),
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
+
+// PutObject mock function.
+func (c *MockClient) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Objects == nil {
+		c.Objects = make(map[string]map[string][]byte)
+	}
+
+	bucket := aws.ToString(params.Bucket)
+
+	if c.Objects[bucket] == nil {
+		c.Objects[bucket] = make(map[string][]byte)
+	}
+
+	c.Objects[bucket][aws.ToString(params.Key)] = body
+
+	return &s3.PutObjectOutput{
+		ResultMetadata: middleware.Metadata{},
+	}, nil
+}
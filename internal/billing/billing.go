@@ -0,0 +1,45 @@
+package billing
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// DefaultFreePaths is the number of paths CloudFront invalidates for free
+	// in each account every month.
+	DefaultFreePaths = 1000
+	// DefaultPricePerPath is what CloudFront charges in USD for each path
+	// beyond the free tier.
+	DefaultPricePerPath = 0.005
+)
+
+// Pricing for CloudFront invalidation paths.
+type Pricing struct {
+	FreePaths    float64
+	PricePerPath float64
+}
+
+// DefaultPricing as published by CloudFront.
+func DefaultPricing() Pricing {
+	return Pricing{
+		FreePaths:    DefaultFreePaths,
+		PricePerPath: DefaultPricePerPath,
+	}
+}
+
+// FreeTierRemaining is how many more paths can be invalidated for free
+// in a month which has already seen the given number of paths.
+func (p Pricing) FreeTierRemaining(paths float64) float64 {
+	return math.Max(p.FreePaths-paths, 0)
+}
+
+// Cost in USD of a month which has seen the given number of paths.
+func (p Pricing) Cost(paths float64) float64 {
+	return math.Max(paths-p.FreePaths, 0) * p.PricePerPath
+}
+
+// Month which CloudFront bills the given time against, such as "2024-03".
+func Month(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
@@ -0,0 +1,24 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPricing(t *testing.T) {
+	pricing := DefaultPricing()
+
+	assert.Equal(t, float64(1000), pricing.FreeTierRemaining(0))
+	assert.Equal(t, float64(1), pricing.FreeTierRemaining(999))
+	assert.Equal(t, float64(0), pricing.FreeTierRemaining(1500))
+
+	assert.Equal(t, float64(0), pricing.Cost(1000))
+	assert.InDelta(t, 2.5, pricing.Cost(1500), 0.000001)
+}
+
+func TestMonth(t *testing.T) {
+	assert.Equal(t, "2024-03", Month(time.Date(2024, time.March, 31, 23, 59, 59, 0, time.UTC)))
+	assert.Equal(t, "2024-04", Month(time.Date(2024, time.April, 1, 10, 0, 0, 0, time.FixedZone("AEST", 10*60*60))))
+}
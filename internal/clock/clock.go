@@ -0,0 +1,28 @@
+package clock
+
+import (
+	"time"
+)
+
+// Interface for telling the time.
+type Interface interface {
+	Now() time.Time
+}
+
+// Real clock which tells the system time.
+type Real struct{}
+
+// Now returns the current system time.
+func (c Real) Now() time.Time {
+	return time.Now()
+}
+
+// Mock clock for testing which is frozen at a fixed time.
+type Mock struct {
+	Time time.Time
+}
+
+// Now returns the frozen time.
+func (c Mock) Now() time.Time {
+	return c.Time
+}
@@ -0,0 +1,302 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+
+	cloudwatchclient "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/cloudwatch"
+)
+
+const (
+	// MaxWidgets is the most widgets CloudWatch allows on a dashboard.
+	MaxWidgets = 500
+	// GridWidth is the number of columns in the dashboard grid.
+	GridWidth = 24
+	// WidgetWidth is the number of columns used by each graph.
+	WidgetWidth = 6
+	// WidgetHeight is the number of rows used by each graph.
+	WidgetHeight = 6
+	// HeadingHeight is the number of rows used by each heading.
+	HeadingHeight = 1
+)
+
+// Graph which is rendered for every target.
+type Graph struct {
+	Title string
+	// Stat used to aggregate the metrics, such as Sum or p90.
+	Stat    string
+	Metrics []string
+}
+
+// Target which a row of graphs is rendered for.
+type Target struct {
+	// Name shown in the heading for the target.
+	Name string
+	// Dimensions of the metrics published for the target.
+	Dimensions []types.Dimension
+}
+
+// Dashboard which is rendered and kept up to date in CloudWatch.
+type Dashboard struct {
+	CloudWatch cloudwatchclient.ClientInterface
+	Name       string
+	Namespace  string
+	Region     string
+	// Period of each point on the graphs.
+	Period time.Duration
+	// Account graphs are rendered first for metrics which are not broken
+	// down by distribution.
+	Account []Graph
+	// AccountDimensions of the metrics on the account graphs, such as the
+	// account they were collected from.
+	AccountDimensions []types.Dimension
+	// Graphs rendered for each target.
+	Graphs []Graph
+}
+
+// Body of a CloudWatch dashboard.
+type Body struct {
+	Widgets []Widget `json:"widgets"`
+}
+
+// Widget on a CloudWatch dashboard.
+type Widget struct {
+	Type       string `json:"type"`
+	X          int    `json:"x"`
+	Y          int    `json:"y"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Properties any    `json:"properties"`
+}
+
+// TextProperties of a text widget.
+type TextProperties struct {
+	Markdown string `json:"markdown"`
+}
+
+// MetricProperties of a metric widget.
+type MetricProperties struct {
+	Title   string  `json:"title"`
+	View    string  `json:"view"`
+	Region  string  `json:"region"`
+	Stat    string  `json:"stat"`
+	Period  int     `json:"period"`
+	Metrics [][]any `json:"metrics"`
+}
+
+// Render the dashboard bodies with a heading and row of graphs for each
+// target, ordered by name. Targets are split across as many bodies as are
+// needed to keep each within the widget limit, with the account graphs on
+// the first.
+func (d *Dashboard) Render(targets []Target) ([]Body, error) {
+	// A heading is rendered along with the graphs for each target.
+	if 1+len(d.Account) > MaxWidgets || 1+len(d.Graphs) > MaxWidgets {
+		return nil, fmt.Errorf("dashboard row exceeds the limit of %d widgets", MaxWidgets)
+	}
+
+	var (
+		bodies = []Body{{}}
+		y      int
+	)
+
+	if len(d.Account) > 0 {
+		y = d.row(&bodies[0], y, "Account", d.Account, d.AccountDimensions)
+	}
+
+	targets = slices.Clone(targets)
+
+	slices.SortFunc(targets, func(a, b Target) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	for _, target := range targets {
+		if len(bodies[len(bodies)-1].Widgets)+1+len(d.Graphs) > MaxWidgets {
+			bodies = append(bodies, Body{})
+			y = 0
+		}
+
+		y = d.row(&bodies[len(bodies)-1], y, target.Name, d.Graphs, target.Dimensions)
+	}
+
+	return bodies, nil
+}
+
+// PageName is the name of the dashboard for the body at the given index,
+// which is the dashboard name for the first and suffixed with the page
+// number for the rest.
+func (d *Dashboard) PageName(index int) string {
+	if index == 0 {
+		return d.Name
+	}
+
+	return fmt.Sprintf("%s-%d", d.Name, index+1)
+}
+
+// row adds a heading and graphs to the body from the given y position,
+// returning the position after them.
+func (d *Dashboard) row(body *Body, y int, heading string, graphs []Graph, dimensions []types.Dimension) int {
+	body.Widgets = append(body.Widgets, Widget{
+		Type:   "text",
+		Y:      y,
+		Width:  GridWidth,
+		Height: HeadingHeight,
+		Properties: TextProperties{
+			Markdown: "## " + heading,
+		},
+	})
+
+	y += HeadingHeight
+
+	for i, graph := range graphs {
+		x := (i * WidgetWidth) % GridWidth
+
+		// Wrap onto a new line when the row is full.
+		if i > 0 && x == 0 {
+			y += WidgetHeight
+		}
+
+		properties := MetricProperties{
+			Title:  graph.Title,
+			View:   "timeSeries",
+			Region: d.Region,
+			Stat:   graph.Stat,
+			Period: int(d.Period.Seconds()),
+		}
+
+		for _, metric := range graph.Metrics {
+			line := []any{d.Namespace, metric}
+
+			for _, dimension := range dimensions {
+				line = append(line, aws.ToString(dimension.Name), aws.ToString(dimension.Value))
+			}
+
+			properties.Metrics = append(properties.Metrics, line)
+		}
+
+		body.Widgets = append(body.Widgets, Widget{
+			Type:       "metric",
+			X:          x,
+			Y:          y,
+			Width:      WidgetWidth,
+			Height:     WidgetHeight,
+			Properties: properties,
+		})
+	}
+
+	if len(graphs) > 0 {
+		y += WidgetHeight
+	}
+
+	return y
+}
+
+// Apply renders the dashboards and puts those which differ from the ones in
+// CloudWatch, so distributions which appear or disappear are kept in sync.
+// Pages which are no longer needed are deleted.
+func (d *Dashboard) Apply(ctx context.Context, targets []Target) error {
+	bodies, err := d.Render(targets)
+	if err != nil {
+		return fmt.Errorf("failed to render dashboard: %w", err)
+	}
+
+	for i, body := range bodies {
+		err = d.put(ctx, d.PageName(i), body)
+		if err != nil {
+			return err
+		}
+	}
+
+	var stale []string
+
+	// Pages are numbered without gaps, so the first which is missing is
+	// the last there is.
+	for i := len(bodies); ; i++ {
+		_, err := d.CloudWatch.GetDashboard(ctx, &cloudwatch.GetDashboardInput{
+			DashboardName: aws.String(d.PageName(i)),
+		})
+		if isNotFound(err) {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to get dashboard: %w", err)
+		}
+
+		stale = append(stale, d.PageName(i))
+	}
+
+	if len(stale) == 0 {
+		return nil
+	}
+
+	_, err = d.CloudWatch.DeleteDashboards(ctx, &cloudwatch.DeleteDashboardsInput{
+		DashboardNames: stale,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete dashboards: %w", err)
+	}
+
+	return nil
+}
+
+// put the body to the dashboard with the given name when it differs from
+// the one in CloudWatch.
+func (d *Dashboard) put(ctx context.Context, name string, body Body) error {
+	encoded, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode dashboard: %w", err)
+	}
+
+	current, err := d.CloudWatch.GetDashboard(ctx, &cloudwatch.GetDashboardInput{
+		DashboardName: aws.String(name),
+	})
+	if err != nil && !isNotFound(err) {
+		return fmt.Errorf("failed to get dashboard: %w", err)
+	}
+
+	if current != nil && equal(aws.ToString(current.DashboardBody), string(encoded)) {
+		return nil
+	}
+
+	_, err = d.CloudWatch.PutDashboard(ctx, &cloudwatch.PutDashboardInput{
+		DashboardName: aws.String(name),
+		DashboardBody: aws.String(string(encoded)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put dashboard: %w", err)
+	}
+
+	return nil
+}
+
+// equal reports whether two dashboard bodies are the same JSON, as the body
+// returned by CloudWatch is not formatted the same as the one which was put.
+func equal(a, b string) bool {
+	var decodedA, decodedB any
+
+	if json.Unmarshal([]byte(a), &decodedA) != nil || json.Unmarshal([]byte(b), &decodedB) != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(decodedA, decodedB)
+}
+
+// isNotFound reports whether the error is because the dashboard does not exist.
+func isNotFound(err error) bool {
+	var (
+		notFound          *types.ResourceNotFound
+		dashboardNotFound *types.DashboardNotFoundError
+	)
+
+	return errors.As(err, &notFound) || errors.As(err, &dashboardNotFound)
+}
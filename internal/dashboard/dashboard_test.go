@@ -0,0 +1,206 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/stretchr/testify/assert"
+
+	cloudwatchclient "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/cloudwatch"
+)
+
+func target(id string) Target {
+	return Target{
+		Name: id,
+		Dimensions: []types.Dimension{
+			{Name: aws.String("Distribution"), Value: aws.String(id)},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	d := &Dashboard{
+		Namespace: "Skpr/CloudFront",
+		Region:    "ap-southeast-2",
+		Period:    5 * time.Minute,
+		Account: []Graph{
+			{Title: "Cost", Stat: "Maximum", Metrics: []string{"InvalidationEstimatedCostUSD"}},
+		},
+		Graphs: []Graph{
+			{Title: "Invalidations", Stat: "Sum", Metrics: []string{"InvalidationRequest", "InvalidationPathCounter"}},
+			{Title: "A", Stat: "Sum", Metrics: []string{"A"}},
+			{Title: "B", Stat: "Sum", Metrics: []string{"B"}},
+			{Title: "C", Stat: "Sum", Metrics: []string{"C"}},
+			// Wraps onto a new line.
+			{Title: "D", Stat: "Sum", Metrics: []string{"D"}},
+		},
+	}
+
+	bodies, err := d.Render([]Target{target("dist-b"), target("dist-a")})
+	assert.NoError(t, err)
+	assert.Len(t, bodies, 1)
+
+	body := bodies[0]
+
+	// Heading and graph for the account, then a heading and graphs per distribution.
+	assert.Len(t, body.Widgets, 2+2*6)
+
+	assert.Equal(t, TextProperties{Markdown: "## Account"}, body.Widgets[0].Properties)
+	assert.Equal(t, MetricProperties{
+		Title:   "Cost",
+		View:    "timeSeries",
+		Region:  "ap-southeast-2",
+		Stat:    "Maximum",
+		Period:  300,
+		Metrics: [][]any{{"Skpr/CloudFront", "InvalidationEstimatedCostUSD"}},
+	}, body.Widgets[1].Properties)
+
+	// Distributions are ordered by name.
+	assert.Equal(t, TextProperties{Markdown: "## dist-a"}, body.Widgets[2].Properties)
+	assert.Equal(t, 7, body.Widgets[2].Y)
+	assert.Equal(t, [][]any{
+		{"Skpr/CloudFront", "InvalidationRequest", "Distribution", "dist-a"},
+		{"Skpr/CloudFront", "InvalidationPathCounter", "Distribution", "dist-a"},
+	}, body.Widgets[3].Properties.(MetricProperties).Metrics)
+
+	assert.Equal(t, 18, body.Widgets[6].X)
+	assert.Equal(t, 8, body.Widgets[6].Y)
+	assert.Equal(t, 0, body.Widgets[7].X)
+	assert.Equal(t, 14, body.Widgets[7].Y)
+
+	assert.Equal(t, TextProperties{Markdown: "## dist-b"}, body.Widgets[8].Properties)
+	assert.Equal(t, 20, body.Widgets[8].Y)
+}
+
+func TestRenderAccountDimensions(t *testing.T) {
+	d := &Dashboard{
+		Namespace: "Skpr/CloudFront",
+		Account: []Graph{
+			{Title: "Cost", Stat: "Maximum", Metrics: []string{"InvalidationEstimatedCostUSD"}},
+		},
+		AccountDimensions: []types.Dimension{
+			{Name: aws.String("AccountId"), Value: aws.String("111111111111")},
+		},
+	}
+
+	bodies, err := d.Render(nil)
+	assert.NoError(t, err)
+	assert.Len(t, bodies, 1)
+
+	// Account graphs match the dimensions the account metrics are published with.
+	assert.Equal(t, [][]any{
+		{"Skpr/CloudFront", "InvalidationEstimatedCostUSD", "AccountId", "111111111111"},
+	}, bodies[0].Widgets[1].Properties.(MetricProperties).Metrics)
+}
+
+func TestRenderPages(t *testing.T) {
+	d := &Dashboard{
+		Name: "CloudFrontInvalidations",
+		Account: []Graph{
+			{Title: "Cost", Stat: "Maximum", Metrics: []string{"InvalidationEstimatedCostUSD"}},
+		},
+		Graphs: []Graph{
+			{Title: "Invalidations", Stat: "Sum", Metrics: []string{"InvalidationRequest"}},
+		},
+	}
+
+	targets := make([]Target, 400)
+	for i := range targets {
+		targets[i] = target(fmt.Sprintf("dist-%03d", i))
+	}
+
+	bodies, err := d.Render(targets)
+	assert.NoError(t, err)
+
+	// The account row leaves room for 249 distributions on the first page.
+	assert.Len(t, bodies, 2)
+	assert.Len(t, bodies[0].Widgets, MaxWidgets)
+	assert.Len(t, bodies[1].Widgets, 2*151)
+
+	// The next page starts from the top with the next distribution.
+	assert.Equal(t, TextProperties{Markdown: "## dist-249"}, bodies[1].Widgets[0].Properties)
+	assert.Equal(t, 0, bodies[1].Widgets[0].Y)
+
+	assert.Equal(t, "CloudFrontInvalidations", d.PageName(0))
+	assert.Equal(t, "CloudFrontInvalidations-2", d.PageName(1))
+
+	// A row which cannot fit on a dashboard of its own is an error.
+	d.Graphs = make([]Graph, MaxWidgets)
+
+	_, err = d.Render(targets)
+	assert.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	cw := &cloudwatchclient.MockClient{}
+
+	d := &Dashboard{
+		CloudWatch: cw,
+		Name:       "CloudFrontInvalidations",
+		Namespace:  "Skpr/CloudFront",
+		Graphs: []Graph{
+			{Title: "Invalidations", Stat: "Sum", Metrics: []string{"InvalidationRequest"}},
+		},
+	}
+
+	err := d.Apply(context.TODO(), []Target{target("dist-a")})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"CloudFrontInvalidations"}, cw.DashboardPuts)
+
+	var body Body
+
+	err = json.Unmarshal([]byte(cw.Dashboards["CloudFrontInvalidations"]), &body)
+	assert.NoError(t, err)
+	assert.Len(t, body.Widgets, 2)
+
+	// Nothing has changed, so the dashboard is not put again.
+	err = d.Apply(context.TODO(), []Target{target("dist-a")})
+	assert.NoError(t, err)
+	assert.Len(t, cw.DashboardPuts, 1)
+
+	// A new distribution is added to the dashboard.
+	err = d.Apply(context.TODO(), []Target{target("dist-a"), target("dist-b")})
+	assert.NoError(t, err)
+	assert.Len(t, cw.DashboardPuts, 2)
+
+	err = json.Unmarshal([]byte(cw.Dashboards["CloudFrontInvalidations"]), &body)
+	assert.NoError(t, err)
+	assert.Len(t, body.Widgets, 4)
+}
+
+func TestApplyPages(t *testing.T) {
+	cw := &cloudwatchclient.MockClient{}
+
+	d := &Dashboard{
+		CloudWatch: cw,
+		Name:       "CloudFrontInvalidations",
+		Namespace:  "Skpr/CloudFront",
+		Graphs: []Graph{
+			{Title: "Invalidations", Stat: "Sum", Metrics: []string{"InvalidationRequest"}},
+		},
+	}
+
+	targets := make([]Target, 600)
+	for i := range targets {
+		targets[i] = target(fmt.Sprintf("dist-%03d", i))
+	}
+
+	err := d.Apply(context.TODO(), targets)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"CloudFrontInvalidations",
+		"CloudFrontInvalidations-2",
+		"CloudFrontInvalidations-3",
+	}, cw.DashboardPuts)
+
+	// Pages which are no longer needed are deleted.
+	err = d.Apply(context.TODO(), targets[:10])
+	assert.NoError(t, err)
+	assert.Len(t, cw.Dashboards, 1)
+	assert.Contains(t, cw.Dashboards, "CloudFrontInvalidations")
+}
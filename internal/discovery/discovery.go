@@ -0,0 +1,205 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+
+	organizationsclient "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/organizations"
+)
+
+const (
+	// DefaultRole is the name of the role assumed in every discovered account.
+	DefaultRole = "cloudfront-invalidation-metrics"
+)
+
+// Discoverer finds the accounts of an organization to collect metrics from.
+type Discoverer struct {
+	Organizations organizationsclient.ClientInterface
+	// Units limit discovery to accounts within these organizational units,
+	// including those nested within them. When empty every account is.
+	Units []string
+	// Tags which accounts must have, keyed by tag key.
+	Tags map[string]string
+}
+
+// New discoverer of the accounts of an organization.
+func New(client organizationsclient.ClientInterface, units []string, tags map[string]string) (*Discoverer, error) {
+	return &Discoverer{
+		Organizations: client,
+		Units:         units,
+		Tags:          tags,
+	}, nil
+}
+
+// Accounts which are active, within the organizational units and have the
+// tags, ordered by ID.
+func (d *Discoverer) Accounts(ctx context.Context) ([]types.Account, error) {
+	var (
+		accounts []types.Account
+		err      error
+	)
+
+	if len(d.Units) == 0 {
+		accounts, err = d.listAccounts(ctx)
+	} else {
+		accounts, err = d.listUnitAccounts(ctx)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var discovered []types.Account
+
+	for _, account := range accounts {
+		if account.Status != types.AccountStatusActive {
+			continue
+		}
+
+		ok, err := d.hasTags(ctx, aws.ToString(account.Id))
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			discovered = append(discovered, account)
+		}
+	}
+
+	slices.SortFunc(discovered, func(a, b types.Account) int {
+		return strings.Compare(aws.ToString(a.Id), aws.ToString(b.Id))
+	})
+
+	return discovered, nil
+}
+
+// listAccounts in the organization.
+func (d *Discoverer) listAccounts(ctx context.Context) ([]types.Account, error) {
+	var accounts []types.Account
+
+	paginator := organizations.NewListAccountsPaginator(d.Organizations, &organizations.ListAccountsInput{})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list accounts: %w", err)
+		}
+
+		accounts = append(accounts, page.Accounts...)
+	}
+
+	return accounts, nil
+}
+
+// listUnitAccounts within the organizational units and those nested within
+// them, listing each account once.
+func (d *Discoverer) listUnitAccounts(ctx context.Context) ([]types.Account, error) {
+	accounts := make(map[string]types.Account)
+
+	for _, unit := range d.Units {
+		err := d.walkUnit(ctx, unit, accounts)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return slices.Collect(maps.Values(accounts)), nil
+}
+
+// walkUnit adds the accounts within an organizational unit, then walks the
+// units nested within it.
+func (d *Discoverer) walkUnit(ctx context.Context, unit string, accounts map[string]types.Account) error {
+	paginator := organizations.NewListAccountsForParentPaginator(d.Organizations, &organizations.ListAccountsForParentInput{
+		ParentId: aws.String(unit),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list accounts for parent: %s: %w", unit, err)
+		}
+
+		for _, account := range page.Accounts {
+			accounts[aws.ToString(account.Id)] = account
+		}
+	}
+
+	units := organizations.NewListOrganizationalUnitsForParentPaginator(d.Organizations, &organizations.ListOrganizationalUnitsForParentInput{
+		ParentId: aws.String(unit),
+	})
+
+	for units.HasMorePages() {
+		page, err := units.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list organizational units for parent: %s: %w", unit, err)
+		}
+
+		for _, child := range page.OrganizationalUnits {
+			err = d.walkUnit(ctx, aws.ToString(child.Id), accounts)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// hasTags reports whether the account has every tag.
+func (d *Discoverer) hasTags(ctx context.Context, id string) (bool, error) {
+	if len(d.Tags) == 0 {
+		return true, nil
+	}
+
+	tags := make(map[string]string)
+
+	paginator := organizations.NewListTagsForResourcePaginator(d.Organizations, &organizations.ListTagsForResourceInput{
+		ResourceId: aws.String(id),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return false, fmt.Errorf("failed to list tags for account: %s: %w", id, err)
+		}
+
+		for _, tag := range page.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+		}
+	}
+
+	for key, value := range d.Tags {
+		if current, ok := tags[key]; !ok || current != value {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// RoleARN of the role with the given name in the account. The role name may
+// include a path, such as "metrics/cloudfront-invalidation-metrics".
+func RoleARN(account types.Account, role string) string {
+	partition := "aws"
+
+	// Accounts outside the standard partition have roles within their own.
+	parsed, err := arn.Parse(aws.ToString(account.Arn))
+	if err == nil {
+		partition = parsed.Partition
+	}
+
+	return arn.ARN{
+		Partition: partition,
+		Service:   "iam",
+		AccountID: aws.ToString(account.Id),
+		Resource:  "role/" + role,
+	}.String()
+}
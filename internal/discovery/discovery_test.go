@@ -0,0 +1,124 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/stretchr/testify/assert"
+
+	organizationsclient "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/organizations"
+)
+
+func TestAccounts(t *testing.T) {
+	client := organizationsclient.MockClient{
+		AccountPages: [][]types.Account{
+			{
+				newAccount("333333333333", types.AccountStatusActive),
+				newAccount("111111111111", types.AccountStatusActive),
+			},
+			{
+				newAccount("222222222222", types.AccountStatusActive),
+				newAccount("444444444444", types.AccountStatusSuspended),
+			},
+		},
+	}
+
+	discoverer, err := New(client, nil, nil)
+	assert.NoError(t, err)
+
+	accounts, err := discoverer.Accounts(context.TODO())
+	assert.NoError(t, err)
+
+	// Every page is listed, skipping accounts which are not active.
+	assert.Equal(t, []string{"111111111111", "222222222222", "333333333333"}, ids(accounts))
+}
+
+func TestAccountsUnits(t *testing.T) {
+	client := organizationsclient.MockClient{
+		AccountPages: [][]types.Account{
+			{
+				newAccount("111111111111", types.AccountStatusActive),
+				newAccount("222222222222", types.AccountStatusActive),
+				newAccount("333333333333", types.AccountStatusActive),
+				newAccount("444444444444", types.AccountStatusActive),
+			},
+		},
+		Children: map[string][]string{
+			"ou-prod":     {"111111111111"},
+			"ou-prod-web": {"222222222222"},
+			"ou-dev":      {"333333333333"},
+		},
+		Units: map[string][]string{
+			"ou-prod": {"ou-prod-web"},
+		},
+	}
+
+	discoverer, err := New(client, []string{"ou-prod"}, nil)
+	assert.NoError(t, err)
+
+	accounts, err := discoverer.Accounts(context.TODO())
+	assert.NoError(t, err)
+
+	// Accounts within nested units are included.
+	assert.Equal(t, []string{"111111111111", "222222222222"}, ids(accounts))
+}
+
+func TestAccountsTags(t *testing.T) {
+	client := organizationsclient.MockClient{
+		AccountPages: [][]types.Account{
+			{
+				newAccount("111111111111", types.AccountStatusActive),
+				newAccount("222222222222", types.AccountStatusActive),
+				newAccount("333333333333", types.AccountStatusActive),
+			},
+		},
+		Tags: map[string][]types.Tag{
+			"111111111111": {
+				{Key: aws.String("CloudFrontMetrics"), Value: aws.String("enabled")},
+				{Key: aws.String("Team"), Value: aws.String("web")},
+			},
+			"222222222222": {
+				{Key: aws.String("CloudFrontMetrics"), Value: aws.String("disabled")},
+			},
+		},
+	}
+
+	discoverer, err := New(client, nil, map[string]string{"CloudFrontMetrics": "enabled"})
+	assert.NoError(t, err)
+
+	accounts, err := discoverer.Accounts(context.TODO())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"111111111111"}, ids(accounts))
+}
+
+func TestRoleARN(t *testing.T) {
+	account := newAccount("111111111111", types.AccountStatusActive)
+	assert.Equal(t, "arn:aws:iam::111111111111:role/cloudfront-invalidation-metrics", RoleARN(account, DefaultRole))
+
+	account.Arn = aws.String("arn:aws-us-gov:organizations::999999999999:account/o-example/111111111111")
+	assert.Equal(t, "arn:aws-us-gov:iam::111111111111:role/metrics/reader", RoleARN(account, "metrics/reader"))
+
+	// The standard partition is assumed when the account has no ARN.
+	account.Arn = nil
+	assert.Equal(t, "arn:aws:iam::111111111111:role/reader", RoleARN(account, "reader"))
+}
+
+func newAccount(id string, status types.AccountStatus) types.Account {
+	return types.Account{
+		Id:     aws.String(id),
+		Arn:    aws.String("arn:aws:organizations::999999999999:account/o-example/" + id),
+		Status: status,
+	}
+}
+
+func ids(accounts []types.Account) []string {
+	var ids []string
+
+	for _, account := range accounts {
+		ids = append(ids, aws.ToString(account.Id))
+	}
+
+	return ids
+}
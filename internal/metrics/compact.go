@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+const (
+	// fieldOverhead is the most bytes a field of a datum adds to the
+	// payload on top of its value, such as
+	// "&MetricData.member.1000.Dimensions.member.30.Value=".
+	fieldOverhead = 52
+)
+
+// compactKey identifies datums which can be compacted together, and
+// reports false for datums which cannot be compacted.
+func compactKey(datum types.MetricDatum) (string, bool) {
+	if datum.StatisticValues != nil {
+		return "", false
+	}
+
+	dimensions := make([]string, len(datum.Dimensions))
+
+	for i, dimension := range datum.Dimensions {
+		dimensions[i] = aws.ToString(dimension.Name) + "=" + aws.ToString(dimension.Value)
+	}
+
+	// Order does not matter to CloudWatch.
+	slices.Sort(dimensions)
+
+	var timestamp int64
+	if datum.Timestamp != nil {
+		timestamp = datum.Timestamp.UnixNano()
+	}
+
+	return fmt.Sprintf("%s|%s|%d|%d|%s",
+		aws.ToString(datum.MetricName),
+		datum.Unit,
+		aws.ToInt32(datum.StorageResolution),
+		timestamp,
+		strings.Join(dimensions, "|"),
+	), true
+}
+
+// compact the values of two datums into one using Values and Counts,
+// reporting false when they would not fit in a single datum.
+func compact(a, b types.MetricDatum) (types.MetricDatum, bool) {
+	values, counts := samples(a)
+
+	for i, value := range b.Values {
+		count := float64(1)
+		if i < len(b.Counts) {
+			count = b.Counts[i]
+		}
+
+		values, counts = addSample(values, counts, value, count)
+	}
+
+	if len(b.Values) == 0 {
+		values, counts = addSample(values, counts, aws.ToFloat64(b.Value), 1)
+	}
+
+	if len(values) > AwsValuesLimit {
+		return a, false
+	}
+
+	merged := a
+	merged.Value = nil
+	merged.Values = values
+	merged.Counts = counts
+
+	return merged, true
+}
+
+// samples of a datum as values and their counts.
+func samples(datum types.MetricDatum) ([]float64, []float64) {
+	if len(datum.Values) == 0 {
+		return []float64{aws.ToFloat64(datum.Value)}, []float64{1}
+	}
+
+	values := slices.Clone(datum.Values)
+	counts := make([]float64, len(values))
+
+	for i := range values {
+		counts[i] = 1
+		if i < len(datum.Counts) {
+			counts[i] = datum.Counts[i]
+		}
+	}
+
+	return values, counts
+}
+
+// addSample to values, counting it against an existing value when it has
+// already been seen.
+func addSample(values, counts []float64, value, count float64) ([]float64, []float64) {
+	if i := slices.Index(values, value); i >= 0 {
+		counts[i] += count
+		return values, counts
+	}
+
+	return append(values, value), append(counts, count)
+}
+
+// datumSize estimates how many bytes a datum adds to a PutMetricData
+// payload, erring on the side of overestimating.
+func datumSize(datum types.MetricDatum) int {
+	size := field(aws.ToString(datum.MetricName)) + field(string(datum.Unit))
+
+	for _, dimension := range datum.Dimensions {
+		size += field(aws.ToString(dimension.Name)) + field(aws.ToString(dimension.Value))
+	}
+
+	if datum.Timestamp != nil {
+		size += field(datum.Timestamp.Format("2006-01-02T15:04:05.999999999Z07:00"))
+	}
+
+	if datum.Value != nil {
+		size += number(*datum.Value)
+	}
+
+	if datum.StorageResolution != nil {
+		size += field(strconv.Itoa(int(*datum.StorageResolution)))
+	}
+
+	if datum.StatisticValues != nil {
+		size += number(aws.ToFloat64(datum.StatisticValues.Maximum)) +
+			number(aws.ToFloat64(datum.StatisticValues.Minimum)) +
+			number(aws.ToFloat64(datum.StatisticValues.Sum)) +
+			number(aws.ToFloat64(datum.StatisticValues.SampleCount))
+	}
+
+	for _, value := range datum.Values {
+		size += number(value)
+	}
+
+	for _, count := range datum.Counts {
+		size += number(count)
+	}
+
+	return size
+}
+
+// field size of a string value in the payload.
+func field(value string) int {
+	return fieldOverhead + len(url.QueryEscape(value))
+}
+
+// number size of a numeric value in the payload.
+func number(value float64) int {
+	return field(strconv.FormatFloat(value, 'g', -1, 64))
+}
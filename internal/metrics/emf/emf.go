@@ -0,0 +1,222 @@
+package emf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+const (
+	// MaxMetrics is the most metrics EMF allows in a single log line.
+	MaxMetrics = 100
+	// MaxValues is the most values EMF allows for a metric in a single log line.
+	MaxValues = 100
+)
+
+// Client which writes metrics as CloudWatch Embedded Metric Format log
+// lines, which CloudWatch extracts as metrics when they are shipped to
+// CloudWatch Logs. It implements metrics.ClientInterface and is safe for
+// concurrent use.
+type Client struct {
+	mu        sync.Mutex
+	Writer    io.Writer
+	Namespace string
+	Data      []types.MetricDatum
+}
+
+// New client which writes metrics to the writer, such as stdout in Lambda.
+func New(writer io.Writer, namespace string) (*Client, error) {
+	return &Client{
+		Writer:    writer,
+		Namespace: namespace,
+	}, nil
+}
+
+// Metadata which tells CloudWatch how to extract metrics from a log line.
+type Metadata struct {
+	Timestamp         int64       `json:"Timestamp"`
+	CloudWatchMetrics []Directive `json:"CloudWatchMetrics"`
+}
+
+// Directive for extracting metrics from a log line.
+type Directive struct {
+	Namespace  string       `json:"Namespace"`
+	Dimensions [][]string   `json:"Dimensions"`
+	Metrics    []Definition `json:"Metrics"`
+}
+
+// Definition of a metric in a log line.
+type Definition struct {
+	Name string `json:"Name"`
+	Unit string `json:"Unit,omitempty"`
+}
+
+// line which is being built from datums sharing a timestamp and dimensions.
+type line struct {
+	timestamp  time.Time
+	dimensions []types.Dimension
+	metrics    []Definition
+	values     map[string]any
+}
+
+// Add metrics to Client.
+func (c *Client) Add(ctx context.Context, datum types.MetricDatum) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Data = append(c.Data, datum)
+
+	return nil
+}
+
+// Flush metrics by writing them as log lines.
+func (c *Client) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, l := range c.lines() {
+		encoded, err := json.Marshal(c.document(l))
+		if err != nil {
+			return fmt.Errorf("failed to encode metrics: %w", err)
+		}
+
+		_, err = c.Writer.Write(append(encoded, '\n'))
+		if err != nil {
+			return fmt.Errorf("failed to write metrics: %w", err)
+		}
+	}
+
+	c.Data = []types.MetricDatum{}
+
+	return nil
+}
+
+// lines groups datums with the same timestamp and dimensions, starting a
+// new line when a metric is repeated or the line is full.
+func (c *Client) lines() []*line {
+	var (
+		lines []*line
+		open  = make(map[string]*line)
+	)
+
+	for _, datum := range c.Data {
+		for _, values := range chunkValues(datum) {
+			key := groupKey(datum)
+			name := aws.ToString(datum.MetricName)
+
+			l, ok := open[key]
+			if ok {
+				_, repeated := l.values[name]
+				if repeated || len(l.metrics) == MaxMetrics {
+					ok = false
+				}
+			}
+
+			if !ok {
+				l = &line{
+					timestamp:  aws.ToTime(datum.Timestamp),
+					dimensions: datum.Dimensions,
+					values:     make(map[string]any),
+				}
+
+				open[key] = l
+				lines = append(lines, l)
+			}
+
+			l.metrics = append(l.metrics, Definition{
+				Name: name,
+				Unit: string(datum.Unit),
+			})
+
+			l.values[name] = values
+		}
+	}
+
+	return lines
+}
+
+// document for a log line, holding the metadata, dimension values and
+// metric values at the top level.
+func (c *Client) document(l *line) map[string]any {
+	document := make(map[string]any, len(l.dimensions)+len(l.values)+1)
+
+	names := make([]string, len(l.dimensions))
+
+	for i, dimension := range l.dimensions {
+		names[i] = aws.ToString(dimension.Name)
+		document[names[i]] = aws.ToString(dimension.Value)
+	}
+
+	for name, value := range l.values {
+		document[name] = value
+	}
+
+	timestamp := l.timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+
+	document["_aws"] = Metadata{
+		Timestamp: timestamp.UnixMilli(),
+		CloudWatchMetrics: []Directive{
+			{
+				Namespace:  c.Namespace,
+				Dimensions: [][]string{names},
+				Metrics:    l.metrics,
+			},
+		},
+	}
+
+	return document
+}
+
+// chunkValues returns the values of a datum, split so that each chunk
+// fits within a single log line. Counts are expanded into repeated values
+// as EMF does not support them.
+func chunkValues(datum types.MetricDatum) []any {
+	if len(datum.Values) == 0 {
+		return []any{aws.ToFloat64(datum.Value)}
+	}
+
+	var values []float64
+
+	for i, value := range datum.Values {
+		count := 1
+		if i < len(datum.Counts) {
+			count = int(datum.Counts[i])
+		}
+
+		for range count {
+			values = append(values, value)
+		}
+	}
+
+	var chunks []any
+
+	for chunk := range slices.Chunk(values, MaxValues) {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+// groupKey identifies datums which can share a log line.
+func groupKey(datum types.MetricDatum) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%d", aws.ToTime(datum.Timestamp).UnixMilli())
+
+	for _, dimension := range datum.Dimensions {
+		fmt.Fprintf(&b, "|%s=%s", aws.ToString(dimension.Name), aws.ToString(dimension.Value))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,111 @@
+package emf
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlush(t *testing.T) {
+	var buf bytes.Buffer
+
+	client, err := New(&buf, "Skpr/CloudFront")
+	assert.NoError(t, err)
+
+	timestamp := time.Date(2024, time.March, 1, 10, 5, 0, 0, time.UTC)
+
+	distribution := []types.Dimension{
+		{Name: aws.String("Distribution"), Value: aws.String("dist-a")},
+	}
+
+	values := make([]float64, 150)
+	for i := range values {
+		values[i] = float64(i)
+	}
+
+	for _, datum := range []types.MetricDatum{
+		{MetricName: aws.String("InvalidationRequest"), Unit: types.StandardUnitCount, Value: aws.Float64(2), Timestamp: aws.Time(timestamp), Dimensions: distribution},
+		{MetricName: aws.String("InvalidationPathQuotaUtilisation"), Unit: types.StandardUnitPercent, Value: aws.Float64(12.5), Timestamp: aws.Time(timestamp), Dimensions: distribution},
+		{MetricName: aws.String("InvalidationCompletionSeconds"), Unit: types.StandardUnitSeconds, Values: values, Timestamp: aws.Time(timestamp), Dimensions: distribution},
+		{MetricName: aws.String("InvalidationEstimatedCostUSD"), Unit: types.StandardUnitNone, Value: aws.Float64(0.5), Timestamp: aws.Time(timestamp)},
+	} {
+		assert.NoError(t, client.Add(context.TODO(), datum))
+	}
+
+	assert.NoError(t, client.Flush(context.TODO()))
+	assert.Empty(t, client.Data)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+	// The completion values do not fit in a single line, so they spill onto a second.
+	assert.Len(t, lines, 3)
+
+	assert.JSONEq(t, `{
+		"_aws": {
+			"Timestamp": 1709287500000,
+			"CloudWatchMetrics": [
+				{
+					"Namespace": "Skpr/CloudFront",
+					"Dimensions": [["Distribution"]],
+					"Metrics": [
+						{"Name": "InvalidationRequest", "Unit": "Count"},
+						{"Name": "InvalidationPathQuotaUtilisation", "Unit": "Percent"},
+						{"Name": "InvalidationCompletionSeconds", "Unit": "Seconds"}
+					]
+				}
+			]
+		},
+		"Distribution": "dist-a",
+		"InvalidationRequest": 2,
+		"InvalidationPathQuotaUtilisation": 12.5,
+		"InvalidationCompletionSeconds": `+encode(t, values[:100])+`
+	}`, lines[0])
+
+	assert.JSONEq(t, `{
+		"_aws": {
+			"Timestamp": 1709287500000,
+			"CloudWatchMetrics": [
+				{
+					"Namespace": "Skpr/CloudFront",
+					"Dimensions": [["Distribution"]],
+					"Metrics": [
+						{"Name": "InvalidationCompletionSeconds", "Unit": "Seconds"}
+					]
+				}
+			]
+		},
+		"Distribution": "dist-a",
+		"InvalidationCompletionSeconds": `+encode(t, values[100:])+`
+	}`, lines[1])
+
+	// Metrics without dimensions are published with an empty dimension set.
+	assert.JSONEq(t, `{
+		"_aws": {
+			"Timestamp": 1709287500000,
+			"CloudWatchMetrics": [
+				{
+					"Namespace": "Skpr/CloudFront",
+					"Dimensions": [[]],
+					"Metrics": [
+						{"Name": "InvalidationEstimatedCostUSD", "Unit": "None"}
+					]
+				}
+			]
+		},
+		"InvalidationEstimatedCostUSD": 0.5
+	}`, lines[2])
+}
+
+func encode(t *testing.T, value any) string {
+	encoded, err := json.Marshal(value)
+	assert.NoError(t, err)
+
+	return string(encoded)
+}
@@ -2,32 +2,63 @@ package metrics
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 
 	cloudwatchclient "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/cloudwatch"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/clock"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/spool"
 )
 
 const (
 	// AwsPayloadLimit is the maximum quality for a data-set to contain
 	// before AWS will reject the payload.
-	AwsPayloadLimit = 20
+	AwsPayloadLimit = 1000
+	// AwsPayloadSizeLimit is the maximum size in bytes of a payload before
+	// AWS will reject it.
+	AwsPayloadSizeLimit = 1 << 20
+	// AwsValuesLimit is the most values a single datum can hold.
+	AwsValuesLimit = 150
 )
 
 // ClientInterface for pushing metrics to CloudWatch.
 type ClientInterface interface {
-	Add(datum types.MetricDatum) error
-	Flush() error
+	Add(ctx context.Context, datum types.MetricDatum) error
+	Flush(ctx context.Context) error
 }
 
-// Client for pushing metrics to CloudWatch.
+// Client for pushing metrics to CloudWatch. It is safe for concurrent use.
 type Client struct {
+	mu         sync.Mutex
 	CloudWatch cloudwatchclient.ClientInterface
 	Namespace  string
 	Data       []types.MetricDatum
 	DryRun     bool
+	// Output is where batches are reported to during a dry run.
+	Output io.Writer
+	// Retry policy for batches which fail to push.
+	Retry Retry
+	// Sleep waits between retries, returning early if the context is done.
+	Sleep func(ctx context.Context, d time.Duration) error
+	// Spool persists batches which fail to push so a later run can replay
+	// them. When nil failed batches are returned as errors.
+	Spool spool.StoreInterface
+	// Clock tells the time batches are spooled and replayed.
+	Clock   clock.Interface
+	retries int
+	// size is the estimated size of the payload for Data.
+	size int
+	// index of datums in Data which others can be compacted into.
+	index map[string]int
 }
 
 // New client for pushing metrics to CloudWatch.
@@ -36,42 +67,193 @@ func New(cloudwatch cloudwatchclient.ClientInterface, namespace string, dryRun b
 		CloudWatch: cloudwatch,
 		Namespace:  namespace,
 		DryRun:     dryRun,
+		Output:     os.Stdout,
+		Retry:      DefaultRetry(),
+		Sleep:      sleep,
+		Clock:      clock.Real{},
 	}, nil
 }
 
 // Add metrics to Client.
-func (c *Client) Add(data types.MetricDatum) error {
-	if len(c.Data) == AwsPayloadLimit {
-		err := c.Flush()
+//
+// Datums which share a name, unit, dimensions and timestamp with one already
+// added are compacted into its Values and Counts, so fewer are sent. The
+// batch is flushed before it would exceed the datum count or payload size
+// which CloudWatch accepts.
+func (c *Client) Add(ctx context.Context, data types.MetricDatum) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, ok := compactKey(data)
+	if ok {
+		if i, found := c.index[key]; found {
+			if merged, ok := compact(c.Data[i], data); ok {
+				size := c.size - datumSize(c.Data[i]) + datumSize(merged)
+
+				if size <= AwsPayloadSizeLimit {
+					c.Data[i] = merged
+					c.size = size
+
+					return nil
+				}
+			}
+		}
+	}
+
+	size := datumSize(data)
+
+	if len(c.Data) == AwsPayloadLimit || c.size+size > AwsPayloadSizeLimit {
+		err := c.flush(ctx)
 		if err != nil {
 			return err
 		}
 	}
 
+	if ok {
+		if c.index == nil {
+			c.index = make(map[string]int)
+		}
+
+		c.index[key] = len(c.Data)
+	}
+
 	c.Data = append(c.Data, data)
+	c.size += size
 
 	return nil
 }
 
 // Flush metrics to CloudWatch.
-func (c *Client) Flush() error {
+func (c *Client) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.flush(ctx)
+}
+
+// flush metrics to CloudWatch, callers must hold the lock.
+func (c *Client) flush(ctx context.Context) error {
+	if len(c.Data) == 0 {
+		return nil
+	}
+
+	// Report what would have been sent, clearing the batch as if it was.
 	if c.DryRun {
+		err := report(c.Output, c.Namespace, c.Data)
+		if err != nil {
+			return fmt.Errorf("failed to report batch: %w", err)
+		}
+
+		c.reset()
+
 		return nil
 	}
 
-	if len(c.Data) == 0 {
+	err := c.push(ctx, c.Namespace, c.Data)
+	if err != nil {
+		// Batches which CloudWatch rejected would be rejected again when
+		// replayed, such as when permission is denied, so they fail the run.
+		if c.Spool == nil || Rejected(err) {
+			return err
+		}
+
+		// Any other failure may not have reached CloudWatch, so the batch
+		// is spooled and the run carries on while a later run replays it.
+		// It is written even if the run is being cancelled, as that is when
+		// it is most needed.
+		entry := spool.NewEntry(c.Clock.Now(), c.Namespace, c.Data)
+
+		spoolErr := c.Spool.Put(context.WithoutCancel(ctx), entry)
+		if spoolErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to spool batch: %w", spoolErr))
+		}
+
+		log.Printf("spooled batch %s of %d metrics which failed to push: %v", entry.ID, len(c.Data), err)
+	}
+
+	c.reset()
+
+	return nil
+}
+
+// Replay batches which were spooled by previous runs, discarding metrics
+// which are too old for CloudWatch to accept. Batches which CloudWatch
+// rejects are dropped, while those which fail for any other reason are left
+// in the spool for the next run.
+func (c *Client) Replay(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.Spool == nil || c.DryRun {
 		return nil
 	}
 
-	_, err := c.CloudWatch.PutMetricData(context.Background(), &cloudwatch.PutMetricDataInput{
-		Namespace:  aws.String(c.Namespace),
-		MetricData: c.Data,
-	})
+	entries, err := c.Spool.List(ctx)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to list spool: %w", err)
 	}
 
-	c.Data = []types.MetricDatum{}
+	for _, entry := range entries {
+		entry = entry.Expire(c.Clock.Now())
 
-	return err
+		if len(entry.Data) > 0 {
+			err = c.push(ctx, entry.Namespace, entry.Data)
+			if err != nil {
+				if !Rejected(err) {
+					return fmt.Errorf("failed to replay batch: %s: %w", entry.ID, err)
+				}
+
+				// A rejected batch would otherwise block newer batches on
+				// every run until it expires.
+				log.Printf("dropping spooled batch %s which was rejected: %v", entry.ID, err)
+			}
+		}
+
+		err = c.Spool.Delete(ctx, entry.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// push a batch to CloudWatch, retrying errors which may succeed when sent
+// again. Callers must hold the lock.
+func (c *Client) push(ctx context.Context, namespace string, data []types.MetricDatum) error {
+	input := &cloudwatch.PutMetricDataInput{
+		Namespace:  aws.String(namespace),
+		MetricData: data,
+	}
+
+	for attempt := 1; ; attempt++ {
+		_, err := c.CloudWatch.PutMetricData(ctx, input)
+		if err == nil {
+			return nil
+		}
+
+		if !Retryable(err) || attempt >= c.Retry.MaxAttempts {
+			return err
+		}
+
+		// The budget is shared by every batch so that a struggling API
+		// cannot stretch a run out indefinitely.
+		if c.retries >= c.Retry.Budget {
+			return fmt.Errorf("retry budget exhausted: %w", err)
+		}
+
+		c.retries++
+
+		err = c.Sleep(ctx, c.Retry.Backoff(attempt))
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// reset the batch once it has been sent, callers must hold the lock.
+func (c *Client) reset() {
+	c.Data = []types.MetricDatum{}
+	c.size = 0
+	c.index = nil
 }
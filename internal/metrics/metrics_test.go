@@ -1,7 +1,13 @@
 package metrics
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
@@ -14,7 +20,7 @@ func TestAdd(t *testing.T) {
 	client, err := New(&client.MockClient{}, "dev/null", false)
 	assert.NoError(t, err)
 
-	err = client.Add(types.MetricDatum{
+	err = client.Add(context.TODO(), types.MetricDatum{
 		MetricName: aws.String("TestResponse"),
 		Value:      aws.Float64(1),
 	})
@@ -30,20 +36,182 @@ func TestFlush(t *testing.T) {
 	client, err := New(cw, "dev/null", false)
 	assert.NoError(t, err)
 
-	// Generate 21 data points.
-	//   * The flush should be triggered after 20
+	// Generate 1001 distinct data points.
+	//   * The flush should be triggered after 1000
 	//   * There should be 1 left over in the client data.
-	for i := 0; i < 21; i++ {
-		err = client.Add(types.MetricDatum{
-			MetricName: aws.String("TestResponse"),
+	for i := 0; i <= AwsPayloadLimit; i++ {
+		err = client.Add(context.TODO(), types.MetricDatum{
+			MetricName: aws.String(fmt.Sprintf("TestResponse%d", i)),
 			Value:      aws.Float64(1),
 		})
 		assert.NoError(t, err)
 	}
 
 	// Test that the CloudWatch client received the data points.
-	assert.Equal(t, 20, len(cw.MetricData))
+	assert.Equal(t, AwsPayloadLimit, len(cw.MetricData))
+	assert.Equal(t, 1, cw.PutMetricDataCalls)
 
 	// Ensure the records were flushed and the remaining records are kept.
 	assert.Equal(t, 1, len(client.Data))
 }
+
+func TestFlushPayloadSize(t *testing.T) {
+	cw := &client.MockClient{}
+
+	client, err := New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	// Large dimensions fill the payload before the datum count is reached.
+	value := strings.Repeat("a", 1000)
+
+	for i := 0; i < 400; i++ {
+		err = client.Add(context.TODO(), types.MetricDatum{
+			MetricName: aws.String(fmt.Sprintf("TestResponse%d", i)),
+			Dimensions: []types.Dimension{
+				{Name: aws.String("LargeA"), Value: aws.String(value)},
+				{Name: aws.String("LargeB"), Value: aws.String(value)},
+				{Name: aws.String("LargeC"), Value: aws.String(value)},
+			},
+			Value: aws.Float64(1),
+		})
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 1, cw.PutMetricDataCalls)
+	assert.Less(t, len(cw.MetricData), 400)
+	assert.Equal(t, 400, len(cw.MetricData)+len(client.Data))
+}
+
+func TestAddCompact(t *testing.T) {
+	cw := &client.MockClient{}
+
+	client, err := New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	timestamp := time.Date(2024, time.March, 1, 10, 5, 0, 0, time.UTC)
+
+	datum := func(distribution string, value float64) types.MetricDatum {
+		return types.MetricDatum{
+			MetricName: aws.String("InvalidationCompletionSeconds"),
+			Dimensions: []types.Dimension{
+				{Name: aws.String("Distribution"), Value: aws.String(distribution)},
+			},
+			Value:     aws.Float64(value),
+			Unit:      types.StandardUnitSeconds,
+			Timestamp: aws.Time(timestamp),
+		}
+	}
+
+	for _, d := range []types.MetricDatum{
+		datum("dist-a", 30),
+		datum("dist-a", 60),
+		datum("dist-a", 30),
+		// Different dimensions are kept separate.
+		datum("dist-b", 30),
+	} {
+		assert.NoError(t, client.Add(context.TODO(), d))
+	}
+
+	// Datums with many values are compacted too.
+	many := datum("dist-a", 0)
+	many.Value = nil
+	many.Values = []float64{60, 90}
+
+	assert.NoError(t, client.Add(context.TODO(), many))
+
+	assert.Len(t, client.Data, 2)
+	assert.Nil(t, client.Data[0].Value)
+	assert.Equal(t, []float64{30, 60, 90}, client.Data[0].Values)
+	assert.Equal(t, []float64{2, 2, 1}, client.Data[0].Counts)
+	assert.Equal(t, float64(30), aws.ToFloat64(client.Data[1].Value))
+
+	// A datum holds at most 150 distinct values, so another is started.
+	for i := 0; i < AwsValuesLimit; i++ {
+		assert.NoError(t, client.Add(context.TODO(), datum("dist-b", float64(i+1000))))
+	}
+
+	assert.Len(t, client.Data, 3)
+	assert.Len(t, client.Data[1].Values, AwsValuesLimit)
+	assert.Equal(t, float64(1149), aws.ToFloat64(client.Data[2].Value))
+}
+
+func TestAddConcurrent(t *testing.T) {
+	cw := &client.MockClient{}
+
+	client, err := New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				assert.NoError(t, client.Add(context.TODO(), types.MetricDatum{
+					MetricName: aws.String("TestResponse"),
+					Value:      aws.Float64(1),
+				}))
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.NoError(t, client.Flush(context.TODO()))
+
+	// Every data point should have been flushed exactly once, compacted
+	// into a single datum.
+	assert.Equal(t, 1, len(cw.MetricData))
+	assert.Equal(t, []float64{100}, cw.MetricData[0].Counts)
+	assert.Equal(t, 0, len(client.Data))
+}
+
+func TestFlushDryRun(t *testing.T) {
+	cw := &client.MockClient{}
+
+	client, err := New(cw, "dev/null", true)
+	assert.NoError(t, err)
+
+	var output bytes.Buffer
+
+	client.Output = &output
+
+	timestamp := time.Date(2024, time.March, 1, 10, 5, 0, 0, time.UTC)
+
+	err = client.Add(context.TODO(), types.MetricDatum{
+		MetricName: aws.String("InvalidationRequest"),
+		Dimensions: []types.Dimension{
+			{Name: aws.String("Distribution"), Value: aws.String("dist-a")},
+		},
+		Value:     aws.Float64(2),
+		Unit:      types.StandardUnitCount,
+		Timestamp: aws.Time(timestamp),
+	})
+	assert.NoError(t, err)
+
+	err = client.Add(context.TODO(), types.MetricDatum{
+		MetricName: aws.String("InvalidationCompletionSeconds"),
+		Values:     []float64{30, 60},
+		Unit:       types.StandardUnitSeconds,
+		Timestamp:  aws.Time(timestamp),
+	})
+	assert.NoError(t, err)
+
+	err = client.Flush(context.TODO())
+	assert.NoError(t, err)
+
+	// Nothing is sent, but the batch is cleared as if it was.
+	assert.Empty(t, cw.MetricData)
+	assert.Empty(t, client.Data)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	assert.Equal(t, []string{
+		"NAMESPACE  METRIC                         DIMENSIONS           VALUE    UNIT     TIMESTAMP",
+		"dev/null   InvalidationRequest            Distribution=dist-a  2        Count    2024-03-01T10:05:00Z",
+		"dev/null   InvalidationCompletionSeconds  -                    [30 60]  Seconds  2024-03-01T10:05:00Z",
+		`{"namespace":"dev/null","metricData":[{"metricName":"InvalidationRequest","dimensions":{"Distribution":"dist-a"},"value":2,"unit":"Count","timestamp":"2024-03-01T10:05:00Z"},{"metricName":"InvalidationCompletionSeconds","values":[30,60],"unit":"Seconds","timestamp":"2024-03-01T10:05:00Z"}]}`,
+	}, lines)
+}
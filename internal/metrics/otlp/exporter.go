@@ -0,0 +1,159 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// ProtocolGRPC exports metrics with gRPC.
+	ProtocolGRPC = "grpc"
+	// ProtocolHTTP exports metrics with protobuf encoded HTTP requests.
+	ProtocolHTTP = "http/protobuf"
+	// MetricsPath is appended to the endpoint of HTTP exporters.
+	MetricsPath = "/v1/metrics"
+)
+
+// NewExporter for the protocol which sends metrics to the endpoint.
+func NewExporter(protocol, endpoint string, headers map[string]string, plaintext bool) (Exporter, error) {
+	switch protocol {
+	case ProtocolGRPC:
+		return NewGRPCExporter(endpoint, headers, plaintext)
+	case ProtocolHTTP, "":
+		return NewHTTPExporter(endpoint, headers)
+	}
+
+	return nil, fmt.Errorf("unknown protocol: %q", protocol)
+}
+
+// HTTPExporter sends metrics to a collector with protobuf encoded HTTP requests.
+type HTTPExporter struct {
+	HTTP *http.Client
+	// URL of the collector, including the metrics path.
+	URL     string
+	Headers map[string]string
+}
+
+// NewHTTPExporter which sends metrics to the collector at the endpoint,
+// such as http://localhost:4318.
+func NewHTTPExporter(endpoint string, headers map[string]string) (*HTTPExporter, error) {
+	return &HTTPExporter{
+		HTTP:    http.DefaultClient,
+		URL:     endpoint + MetricsPath,
+		Headers: headers,
+	}, nil
+}
+
+// Export metrics to the collector.
+func (e *HTTPExporter) Export(ctx context.Context, request *colmetricspb.ExportMetricsServiceRequest) error {
+	body, err := proto.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	for key, value := range e.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.HTTP.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector responded with status: %s", resp.Status)
+	}
+
+	var response colmetricspb.ExportMetricsServiceResponse
+
+	err = proto.Unmarshal(body, &response)
+	if err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return partialSuccess(&response)
+}
+
+// Close does nothing as HTTP requests do not hold a connection.
+func (e *HTTPExporter) Close() error {
+	return nil
+}
+
+// GRPCExporter sends metrics to a collector with gRPC.
+type GRPCExporter struct {
+	conn    *grpc.ClientConn
+	client  colmetricspb.MetricsServiceClient
+	Headers map[string]string
+}
+
+// NewGRPCExporter which sends metrics to the collector at the endpoint,
+// such as localhost:4317. TLS is used unless plaintext is set.
+func NewGRPCExporter(endpoint string, headers map[string]string, plaintext bool) (*GRPCExporter, error) {
+	creds := credentials.NewTLS(nil)
+	if plaintext {
+		creds = insecure.NewCredentials()
+	}
+
+	conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to collector: %w", err)
+	}
+
+	return &GRPCExporter{
+		conn:    conn,
+		client:  colmetricspb.NewMetricsServiceClient(conn),
+		Headers: headers,
+	}, nil
+}
+
+// Export metrics to the collector.
+func (e *GRPCExporter) Export(ctx context.Context, request *colmetricspb.ExportMetricsServiceRequest) error {
+	if len(e.Headers) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(e.Headers))
+	}
+
+	response, err := e.client.Export(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	return partialSuccess(response)
+}
+
+// Close the connection to the collector.
+func (e *GRPCExporter) Close() error {
+	return e.conn.Close()
+}
+
+// partialSuccess returns an error when the collector rejected data points.
+func partialSuccess(response *colmetricspb.ExportMetricsServiceResponse) error {
+	partial := response.GetPartialSuccess()
+	if partial.GetRejectedDataPoints() > 0 {
+		return fmt.Errorf("collector rejected %d data points: %s", partial.GetRejectedDataPoints(), partial.GetErrorMessage())
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+const (
+	// ServiceName is the service.name resource attribute of exported metrics.
+	ServiceName = "cloudfront-invalidation-metrics"
+)
+
+// Exporter sends metrics to an OTLP collector.
+type Exporter interface {
+	Export(ctx context.Context, request *colmetricspb.ExportMetricsServiceRequest) error
+	Close() error
+}
+
+// Client for exporting metrics over OTLP. It implements
+// metrics.ClientInterface and is safe for concurrent use.
+type Client struct {
+	mu       sync.Mutex
+	Exporter Exporter
+	// Namespace is the name of the instrumentation scope of the metrics.
+	Namespace string
+	// Period which each datum was counted over, used as the start time
+	// of counters.
+	Period time.Duration
+	// Counters are the names of metrics which are counted over the period,
+	// and are exported as delta sums. Everything else is a gauge, unless
+	// it has many values in which case it is a histogram.
+	Counters []string
+	Data     []types.MetricDatum
+}
+
+// New client for exporting metrics over OTLP.
+func New(exporter Exporter, namespace string, period time.Duration, counters []string) (*Client, error) {
+	return &Client{
+		Exporter:  exporter,
+		Namespace: namespace,
+		Period:    period,
+		Counters:  counters,
+	}, nil
+}
+
+// Add metrics to Client.
+func (c *Client) Add(ctx context.Context, datum types.MetricDatum) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Data = append(c.Data, datum)
+
+	return nil
+}
+
+// Flush metrics to the collector in a single request.
+func (c *Client) Flush(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.Data) == 0 {
+		return nil
+	}
+
+	err := c.Exporter.Export(ctx, c.request())
+	if err != nil {
+		return fmt.Errorf("failed to export metrics: %w", err)
+	}
+
+	c.Data = []types.MetricDatum{}
+
+	return nil
+}
+
+// Close the connection to the collector.
+func (c *Client) Close() error {
+	return c.Exporter.Close()
+}
+
+// request converts the data into an export request, with a metric for each
+// name and unit holding a data point for each datum.
+func (c *Client) request() *colmetricspb.ExportMetricsServiceRequest {
+	var (
+		metrics []*metricspb.Metric
+		index   = make(map[string]*metricspb.Metric)
+	)
+
+	for _, datum := range c.Data {
+		name := aws.ToString(datum.MetricName)
+		key := name + "/" + string(datum.Unit)
+
+		metric, ok := index[key]
+		if !ok {
+			metric = c.metric(datum)
+			index[key] = metric
+			metrics = append(metrics, metric)
+		}
+
+		c.addDataPoint(metric, datum)
+	}
+
+	return &colmetricspb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{
+			{
+				Resource: &resourcepb.Resource{
+					Attributes: []*commonpb.KeyValue{
+						stringAttribute("service.name", ServiceName),
+					},
+				},
+				ScopeMetrics: []*metricspb.ScopeMetrics{
+					{
+						Scope: &commonpb.InstrumentationScope{
+							Name: c.Namespace,
+						},
+						Metrics: metrics,
+					},
+				},
+			},
+		},
+	}
+}
+
+// metric which the datum is exported as, without any data points.
+func (c *Client) metric(datum types.MetricDatum) *metricspb.Metric {
+	metric := &metricspb.Metric{
+		Name: aws.ToString(datum.MetricName),
+		Unit: Unit(datum.Unit),
+	}
+
+	switch {
+	case len(datum.Values) > 0:
+		metric.Data = &metricspb.Metric_Histogram{
+			Histogram: &metricspb.Histogram{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+			},
+		}
+	case slices.Contains(c.Counters, aws.ToString(datum.MetricName)):
+		metric.Data = &metricspb.Metric_Sum{
+			Sum: &metricspb.Sum{
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA,
+				IsMonotonic:            true,
+			},
+		}
+	default:
+		metric.Data = &metricspb.Metric_Gauge{
+			Gauge: &metricspb.Gauge{},
+		}
+	}
+
+	return metric
+}
+
+// addDataPoint for the datum to the metric.
+func (c *Client) addDataPoint(metric *metricspb.Metric, datum types.MetricDatum) {
+	var (
+		end        = aws.ToTime(datum.Timestamp)
+		start      = end.Add(-c.Period)
+		attributes = make([]*commonpb.KeyValue, len(datum.Dimensions))
+	)
+
+	for i, dimension := range datum.Dimensions {
+		attributes[i] = stringAttribute(aws.ToString(dimension.Name), aws.ToString(dimension.Value))
+	}
+
+	switch data := metric.Data.(type) {
+	case *metricspb.Metric_Histogram:
+		var (
+			count   uint64
+			sum     float64
+			lowest  = datum.Values[0]
+			highest = datum.Values[0]
+		)
+
+		for i, value := range datum.Values {
+			n := float64(1)
+			if i < len(datum.Counts) {
+				n = datum.Counts[i]
+			}
+
+			count += uint64(n)
+			sum += value * n
+			lowest = min(lowest, value)
+			highest = max(highest, value)
+		}
+
+		data.Histogram.DataPoints = append(data.Histogram.DataPoints, &metricspb.HistogramDataPoint{
+			Attributes:        attributes,
+			StartTimeUnixNano: unixNano(start),
+			TimeUnixNano:      unixNano(end),
+			Count:             count,
+			Sum:               aws.Float64(sum),
+			Min:               aws.Float64(lowest),
+			Max:               aws.Float64(highest),
+			// A single bucket holding every value.
+			BucketCounts: []uint64{count},
+		})
+	case *metricspb.Metric_Sum:
+		data.Sum.DataPoints = append(data.Sum.DataPoints, &metricspb.NumberDataPoint{
+			Attributes:        attributes,
+			StartTimeUnixNano: unixNano(start),
+			TimeUnixNano:      unixNano(end),
+			Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: aws.ToFloat64(datum.Value)},
+		})
+	case *metricspb.Metric_Gauge:
+		data.Gauge.DataPoints = append(data.Gauge.DataPoints, &metricspb.NumberDataPoint{
+			Attributes:   attributes,
+			TimeUnixNano: unixNano(end),
+			Value:        &metricspb.NumberDataPoint_AsDouble{AsDouble: aws.ToFloat64(datum.Value)},
+		})
+	}
+}
+
+// Unit converts a CloudWatch unit into a UCUM unit as used by OpenTelemetry.
+func Unit(unit types.StandardUnit) string {
+	switch unit {
+	case types.StandardUnitCount:
+		return "1"
+	case types.StandardUnitPercent:
+		return "%"
+	case types.StandardUnitSeconds:
+		return "s"
+	case types.StandardUnitMilliseconds:
+		return "ms"
+	case types.StandardUnitMicroseconds:
+		return "us"
+	case types.StandardUnitBytes:
+		return "By"
+	case types.StandardUnitKilobytes:
+		return "kBy"
+	case types.StandardUnitMegabytes:
+		return "MBy"
+	case types.StandardUnitCountSecond:
+		return "1/s"
+	}
+
+	return ""
+}
+
+// stringAttribute with the given key and value.
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key: key,
+		Value: &commonpb.AnyValue{
+			Value: &commonpb.AnyValue_StringValue{StringValue: value},
+		},
+	}
+}
+
+// unixNano of the time, or zero when it is not set.
+func unixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return uint64(t.UnixNano())
+}
@@ -0,0 +1,175 @@
+package otlp
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/stretchr/testify/assert"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// collector is an in-process stand-in for an OTLP collector which records
+// the requests it receives over HTTP and gRPC.
+type collector struct {
+	colmetricspb.UnimplementedMetricsServiceServer
+	mu       sync.Mutex
+	requests []*colmetricspb.ExportMetricsServiceRequest
+	headers  []string
+}
+
+// Export records a request received over gRPC.
+func (c *collector) Export(ctx context.Context, request *colmetricspb.ExportMetricsServiceRequest) (*colmetricspb.ExportMetricsServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	c.requests = append(c.requests, request)
+	c.headers = append(c.headers, md.Get("x-api-key")...)
+
+	return &colmetricspb.ExportMetricsServiceResponse{}, nil
+}
+
+// ServeHTTP records a request received over HTTP.
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if r.URL.Path != MetricsPath || r.Header.Get("Content-Type") != "application/x-protobuf" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var request colmetricspb.ExportMetricsServiceRequest
+
+	err = proto.Unmarshal(body, &request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.requests = append(c.requests, &request)
+	c.headers = append(c.headers, r.Header.Get("X-Api-Key"))
+
+	response, _ := proto.Marshal(&colmetricspb.ExportMetricsServiceResponse{})
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(response)
+}
+
+func testData(timestamp time.Time) []types.MetricDatum {
+	dimensions := []types.Dimension{
+		{Name: aws.String("Distribution"), Value: aws.String("dist-a")},
+	}
+
+	return []types.MetricDatum{
+		{MetricName: aws.String("InvalidationRequest"), Unit: types.StandardUnitCount, Value: aws.Float64(2), Timestamp: aws.Time(timestamp), Dimensions: dimensions},
+		{MetricName: aws.String("InvalidationsInProgress"), Unit: types.StandardUnitCount, Value: aws.Float64(1), Timestamp: aws.Time(timestamp), Dimensions: dimensions},
+		{MetricName: aws.String("InvalidationCompletionSeconds"), Unit: types.StandardUnitSeconds, Values: []float64{30, 90}, Timestamp: aws.Time(timestamp), Dimensions: dimensions},
+	}
+}
+
+func assertRequest(t *testing.T, request *colmetricspb.ExportMetricsServiceRequest, timestamp time.Time) {
+	scope := request.ResourceMetrics[0].ScopeMetrics[0]
+	assert.Equal(t, "Skpr/CloudFront", scope.Scope.Name)
+	assert.Len(t, scope.Metrics, 3)
+
+	requests := scope.Metrics[0]
+	assert.Equal(t, "InvalidationRequest", requests.Name)
+	assert.Equal(t, "1", requests.Unit)
+
+	sum := requests.GetSum()
+	assert.True(t, sum.IsMonotonic)
+	assert.Equal(t, metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA, sum.AggregationTemporality)
+	assert.Equal(t, float64(2), sum.DataPoints[0].GetAsDouble())
+	assert.Equal(t, uint64(timestamp.Add(-5*time.Minute).UnixNano()), sum.DataPoints[0].StartTimeUnixNano)
+	assert.Equal(t, uint64(timestamp.UnixNano()), sum.DataPoints[0].TimeUnixNano)
+	assert.Equal(t, "Distribution", sum.DataPoints[0].Attributes[0].Key)
+	assert.Equal(t, "dist-a", sum.DataPoints[0].Attributes[0].Value.GetStringValue())
+
+	gauge := scope.Metrics[1].GetGauge()
+	assert.Equal(t, float64(1), gauge.DataPoints[0].GetAsDouble())
+
+	histogram := scope.Metrics[2].GetHistogram()
+	assert.Equal(t, "s", scope.Metrics[2].Unit)
+	assert.Equal(t, uint64(2), histogram.DataPoints[0].Count)
+	assert.Equal(t, float64(120), histogram.DataPoints[0].GetSum())
+	assert.Equal(t, float64(30), histogram.DataPoints[0].GetMin())
+	assert.Equal(t, float64(90), histogram.DataPoints[0].GetMax())
+}
+
+func TestHTTP(t *testing.T) {
+	stub := &collector{}
+
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	exporter, err := NewExporter(ProtocolHTTP, server.URL, map[string]string{"X-Api-Key": "secret"}, false)
+	assert.NoError(t, err)
+
+	testClient(t, exporter, stub)
+}
+
+func TestGRPC(t *testing.T) {
+	stub := &collector{}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	server := grpc.NewServer()
+	colmetricspb.RegisterMetricsServiceServer(server, stub)
+
+	go server.Serve(listener)
+	defer server.Stop()
+
+	exporter, err := NewExporter(ProtocolGRPC, listener.Addr().String(), map[string]string{"X-Api-Key": "secret"}, true)
+	assert.NoError(t, err)
+
+	testClient(t, exporter, stub)
+}
+
+func testClient(t *testing.T, exporter Exporter, stub *collector) {
+	timestamp := time.Date(2024, time.March, 1, 10, 5, 0, 0, time.UTC)
+
+	client, err := New(exporter, "Skpr/CloudFront", 5*time.Minute, []string{"InvalidationRequest"})
+	assert.NoError(t, err)
+
+	defer client.Close()
+
+	for _, datum := range testData(timestamp) {
+		assert.NoError(t, client.Add(context.TODO(), datum))
+	}
+
+	assert.NoError(t, client.Flush(context.TODO()))
+	assert.Empty(t, client.Data)
+
+	// Nothing is exported when there is nothing to flush.
+	assert.NoError(t, client.Flush(context.TODO()))
+
+	assert.Len(t, stub.requests, 1)
+	assert.Equal(t, []string{"secret"}, stub.headers)
+	assertRequest(t, stub.requests[0], timestamp)
+}
+
+func TestNewExporter(t *testing.T) {
+	_, err := NewExporter("carrier-pigeon", "localhost", nil, false)
+	assert.Error(t, err)
+}
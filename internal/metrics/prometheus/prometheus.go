@@ -0,0 +1,326 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// ContentType of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Type of a Prometheus metric.
+type Type string
+
+const (
+	// Counter accumulates the value of every datum.
+	Counter Type = "counter"
+	// Gauge is set to the value of the latest datum.
+	Gauge Type = "gauge"
+	// Summary accumulates the sum and count of datums with many values.
+	Summary Type = "summary"
+)
+
+// Registry which collects metrics and serves them in the Prometheus text
+// exposition format. It implements metrics.ClientInterface so that it can
+// be used in place of CloudWatch, and is safe for concurrent use.
+type Registry struct {
+	mu sync.Mutex
+	// Prefix added to the name of every metric.
+	Prefix string
+	// Types of metrics by their CloudWatch name. Metrics which are not
+	// listed are gauges, unless they have many values.
+	Types   map[string]Type
+	pending []types.MetricDatum
+	series  map[string]*series
+	// seen are the gauges flushed since Begin, or nil outside of a cycle.
+	seen map[string]bool
+}
+
+// series of a metric with a set of labels.
+type series struct {
+	name   string
+	labels string
+	typ    Type
+	value  float64
+	sum    float64
+	count  float64
+}
+
+// New registry for serving metrics to Prometheus.
+func New(prefix string, metricTypes map[string]Type) (*Registry, error) {
+	return &Registry{
+		Prefix: prefix,
+		Types:  metricTypes,
+		series: make(map[string]*series),
+	}, nil
+}
+
+// Add metrics to the Registry. They are not served until flushed.
+func (r *Registry) Add(ctx context.Context, datum types.MetricDatum) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending = append(r.pending, datum)
+
+	return nil
+}
+
+// Begin a collection cycle which flushes many times, such as once for each
+// account. Gauges are only replaced when the cycle is committed, rather than
+// by every flush within it.
+func (r *Registry) Begin() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.seen = make(map[string]bool)
+}
+
+// Commit the collection cycle, dropping gauges which were not flushed
+// within it.
+func (r *Registry) Commit() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, s := range r.series {
+		if s.typ == Gauge && !r.seen[key] {
+			delete(r.series, key)
+		}
+	}
+
+	r.seen = nil
+}
+
+// Flush metrics so they are served. Counters and summaries accumulate,
+// while gauges are replaced by those flushed so that series for
+// distributions which no longer exist are dropped. Within a collection
+// cycle gauges are replaced when it is committed instead.
+func (r *Registry) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.seen == nil {
+		for key, s := range r.series {
+			if s.typ == Gauge {
+				delete(r.series, key)
+			}
+		}
+	}
+
+	for _, datum := range r.pending {
+		typ := r.typeOf(datum)
+		name := r.name(datum, typ)
+		labels := formatLabels(datum.Dimensions)
+		key := name + labels
+
+		s, ok := r.series[key]
+		if !ok {
+			s = &series{
+				name:   name,
+				labels: labels,
+				typ:    typ,
+			}
+
+			r.series[key] = s
+		}
+
+		if r.seen != nil && typ == Gauge {
+			r.seen[key] = true
+		}
+
+		switch typ {
+		case Counter:
+			s.value += aws.ToFloat64(datum.Value)
+		case Gauge:
+			s.value = aws.ToFloat64(datum.Value)
+		case Summary:
+			for i, value := range datum.Values {
+				count := float64(1)
+				if i < len(datum.Counts) {
+					count = datum.Counts[i]
+				}
+
+				s.sum += value * count
+				s.count += count
+			}
+		}
+	}
+
+	r.pending = nil
+
+	return nil
+}
+
+// typeOf the metric which a datum is for.
+func (r *Registry) typeOf(datum types.MetricDatum) Type {
+	if len(datum.Values) > 0 {
+		return Summary
+	}
+
+	if typ, ok := r.Types[aws.ToString(datum.MetricName)]; ok && typ != Summary {
+		return typ
+	}
+
+	return Gauge
+}
+
+// name of the metric which a datum is for, following Prometheus naming
+// conventions such as snake case and unit and counter suffixes.
+func (r *Registry) name(datum types.MetricDatum, typ Type) string {
+	name := r.Prefix + snakeCase(aws.ToString(datum.MetricName))
+
+	switch datum.Unit {
+	case types.StandardUnitSeconds:
+		if !strings.HasSuffix(name, "_seconds") {
+			name += "_seconds"
+		}
+	case types.StandardUnitPercent:
+		if !strings.HasSuffix(name, "_percent") {
+			name += "_percent"
+		}
+	}
+
+	if typ == Counter {
+		name += "_total"
+	}
+
+	return name
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]string, 0, len(r.series))
+
+	for key := range r.series {
+		keys = append(keys, key)
+	}
+
+	slices.SortFunc(keys, func(a, b string) int {
+		sa, sb := r.series[a], r.series[b]
+
+		if c := strings.Compare(sa.name, sb.name); c != 0 {
+			return c
+		}
+
+		return strings.Compare(sa.labels, sb.labels)
+	})
+
+	var (
+		b    strings.Builder
+		last string
+	)
+
+	for _, key := range keys {
+		s := r.series[key]
+
+		if s.name != last {
+			fmt.Fprintf(&b, "# TYPE %s %s\n", s.name, s.typ)
+			last = s.name
+		}
+
+		switch s.typ {
+		case Summary:
+			fmt.Fprintf(&b, "%s_sum%s %s\n", s.name, s.labels, formatValue(s.sum))
+			fmt.Fprintf(&b, "%s_count%s %s\n", s.name, s.labels, formatValue(s.count))
+		default:
+			fmt.Fprintf(&b, "%s%s %s\n", s.name, s.labels, formatValue(s.value))
+		}
+	}
+
+	n, err := io.WriteString(w, b.String())
+
+	return int64(n), err
+}
+
+// ServeHTTP serves the metrics to a Prometheus scrape.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+
+	_, err := r.WriteTo(w)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// formatLabels from dimensions, sorted by name.
+func formatLabels(dimensions []types.Dimension) string {
+	if len(dimensions) == 0 {
+		return ""
+	}
+
+	labels := make([]string, len(dimensions))
+
+	for i, dimension := range dimensions {
+		labels[i] = fmt.Sprintf(`%s="%s"`, labelName(aws.ToString(dimension.Name)), escape(aws.ToString(dimension.Value)))
+	}
+
+	slices.Sort(labels)
+
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// labelName converts a dimension name into a valid label name.
+func labelName(name string) string {
+	name = snakeCase(name)
+
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "_" + name
+	}
+
+	return name
+}
+
+// labelEscaper escapes the characters which are not allowed in label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escape a label value.
+func escape(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+// formatValue as a Prometheus sample value.
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// snakeCase converts a CamelCase name into snake_case, replacing anything
+// which is not valid in a Prometheus name with an underscore.
+func snakeCase(name string) string {
+	var (
+		b     strings.Builder
+		runes = []rune(name)
+	)
+
+	for i, r := range runes {
+		if !(r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))) {
+			b.WriteRune('_')
+			continue
+		}
+
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+
+			// Start a new word, keeping acronyms such as USD together.
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteRune('_')
+			}
+		}
+
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,151 @@
+package prometheus
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func datum(name string, unit types.StandardUnit, value float64, distribution string) types.MetricDatum {
+	return types.MetricDatum{
+		MetricName: aws.String(name),
+		Unit:       unit,
+		Value:      aws.Float64(value),
+		Dimensions: []types.Dimension{
+			{Name: aws.String("Distribution"), Value: aws.String(distribution)},
+		},
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	registry, err := New("cloudfront_", map[string]Type{
+		"InvalidationRequest": Counter,
+	})
+	assert.NoError(t, err)
+
+	for _, d := range []types.MetricDatum{
+		datum("InvalidationRequest", types.StandardUnitCount, 2, "dist-a"),
+		datum("InvalidationsInProgress", types.StandardUnitCount, 1, "dist-a"),
+		datum("InvalidationsInProgress", types.StandardUnitCount, 3, "dist-b"),
+		datum("InvalidationPathQuotaUtilisation", types.StandardUnitPercent, 12.5, "dist-a"),
+		{
+			MetricName: aws.String("InvalidationCompletionSeconds"),
+			Unit:       types.StandardUnitSeconds,
+			Values:     []float64{30, 60},
+			Dimensions: []types.Dimension{
+				{Name: aws.String("Distribution"), Value: aws.String("dist-a")},
+			},
+		},
+		{
+			MetricName: aws.String("InvalidationEstimatedCostUSD"),
+			Unit:       types.StandardUnitNone,
+			Value:      aws.Float64(0.5),
+		},
+	} {
+		assert.NoError(t, registry.Add(context.TODO(), d))
+	}
+
+	// Nothing is served until flushed.
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Empty(t, recorder.Body.String())
+
+	assert.NoError(t, registry.Flush(context.TODO()))
+
+	// Counters accumulate and gauges are replaced, dropping dist-b.
+	for _, d := range []types.MetricDatum{
+		datum("InvalidationRequest", types.StandardUnitCount, 3, "dist-a"),
+		datum("InvalidationsInProgress", types.StandardUnitCount, 0, "dist-a"),
+	} {
+		assert.NoError(t, registry.Add(context.TODO(), d))
+	}
+
+	assert.NoError(t, registry.Flush(context.TODO()))
+
+	recorder = httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, err := io.ReadAll(recorder.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, ContentType, recorder.Header().Get("Content-Type"))
+	assert.Equal(t, `# TYPE cloudfront_invalidation_completion_seconds summary
+cloudfront_invalidation_completion_seconds_sum{distribution="dist-a"} 90
+cloudfront_invalidation_completion_seconds_count{distribution="dist-a"} 2
+# TYPE cloudfront_invalidation_request_total counter
+cloudfront_invalidation_request_total{distribution="dist-a"} 5
+# TYPE cloudfront_invalidations_in_progress gauge
+cloudfront_invalidations_in_progress{distribution="dist-a"} 0
+`, string(body))
+}
+
+func TestRegistryCycle(t *testing.T) {
+	registry, err := New("cloudfront_", nil)
+	assert.NoError(t, err)
+
+	flush := func(data ...types.MetricDatum) {
+		for _, d := range data {
+			assert.NoError(t, registry.Add(context.TODO(), d))
+		}
+
+		assert.NoError(t, registry.Flush(context.TODO()))
+	}
+
+	registry.Begin()
+	flush(datum("InvalidationsInProgress", types.StandardUnitCount, 1, "dist-a"))
+	flush(datum("InvalidationsInProgress", types.StandardUnitCount, 2, "dist-b"))
+	flush(datum("InvalidationsInProgress", types.StandardUnitCount, 3, "dist-c"))
+	registry.Commit()
+
+	// Each flush within the cycle keeps the gauges of those before it.
+	var body strings.Builder
+
+	_, err = registry.WriteTo(&body)
+	assert.NoError(t, err)
+	assert.Equal(t, `# TYPE cloudfront_invalidations_in_progress gauge
+cloudfront_invalidations_in_progress{distribution="dist-a"} 1
+cloudfront_invalidations_in_progress{distribution="dist-b"} 2
+cloudfront_invalidations_in_progress{distribution="dist-c"} 3
+`, body.String())
+
+	// Gauges which were not flushed in the next cycle are dropped when it
+	// is committed.
+	registry.Begin()
+	flush(datum("InvalidationsInProgress", types.StandardUnitCount, 4, "dist-a"))
+	flush(datum("InvalidationsInProgress", types.StandardUnitCount, 5, "dist-b"))
+	registry.Commit()
+
+	body.Reset()
+
+	_, err = registry.WriteTo(&body)
+	assert.NoError(t, err)
+	assert.Equal(t, `# TYPE cloudfront_invalidations_in_progress gauge
+cloudfront_invalidations_in_progress{distribution="dist-a"} 4
+cloudfront_invalidations_in_progress{distribution="dist-b"} 5
+`, body.String())
+}
+
+func TestFormatLabels(t *testing.T) {
+	assert.Equal(t, `{environment="prod",primary_alias="a\"b\\c\nd"}`, formatLabels([]types.Dimension{
+		{Name: aws.String("PrimaryAlias"), Value: aws.String("a\"b\\c\nd")},
+		{Name: aws.String("Environment"), Value: aws.String("prod")},
+	}))
+}
+
+func TestSnakeCase(t *testing.T) {
+	for input, expected := range map[string]string{
+		"InvalidationRequest":          "invalidation_request",
+		"InvalidationEstimatedCostUSD": "invalidation_estimated_cost_usd",
+		"USDCost":                      "usd_cost",
+		"aws:cloudformation:stack":     "aws_cloudformation_stack",
+		"Path2Group":                   "path2_group",
+	} {
+		assert.Equal(t, expected, snakeCase(input), input)
+	}
+}
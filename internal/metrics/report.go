@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+// Batch of metrics as reported during a dry run.
+type Batch struct {
+	Namespace  string   `json:"namespace"`
+	MetricData []Metric `json:"metricData"`
+}
+
+// Metric as reported during a dry run.
+type Metric struct {
+	MetricName string            `json:"metricName"`
+	Dimensions map[string]string `json:"dimensions,omitempty"`
+	Value      *float64          `json:"value,omitempty"`
+	Values     []float64         `json:"values,omitempty"`
+	Counts     []float64         `json:"counts,omitempty"`
+	Unit       string            `json:"unit,omitempty"`
+	Timestamp  *time.Time        `json:"timestamp,omitempty"`
+}
+
+// newBatch converts data into a batch which can be reported.
+func newBatch(namespace string, data []types.MetricDatum) Batch {
+	batch := Batch{
+		Namespace:  namespace,
+		MetricData: make([]Metric, len(data)),
+	}
+
+	for i, datum := range data {
+		metric := Metric{
+			MetricName: aws.ToString(datum.MetricName),
+			Value:      datum.Value,
+			Values:     datum.Values,
+			Counts:     datum.Counts,
+			Unit:       string(datum.Unit),
+			Timestamp:  datum.Timestamp,
+		}
+
+		if len(datum.Dimensions) > 0 {
+			metric.Dimensions = make(map[string]string, len(datum.Dimensions))
+
+			for _, dimension := range datum.Dimensions {
+				metric.Dimensions[aws.ToString(dimension.Name)] = aws.ToString(dimension.Value)
+			}
+		}
+
+		batch.MetricData[i] = metric
+	}
+
+	return batch
+}
+
+// report a batch which would be sent as a human-readable table followed
+// by a line of JSON.
+func report(w io.Writer, namespace string, data []types.MetricDatum) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "NAMESPACE\tMETRIC\tDIMENSIONS\tVALUE\tUNIT\tTIMESTAMP")
+
+	for _, datum := range data {
+		dimensions := make([]string, len(datum.Dimensions))
+
+		for i, dimension := range datum.Dimensions {
+			dimensions[i] = aws.ToString(dimension.Name) + "=" + aws.ToString(dimension.Value)
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			namespace,
+			aws.ToString(datum.MetricName),
+			orDash(strings.Join(dimensions, ",")),
+			formatValue(datum),
+			orDash(string(datum.Unit)),
+			formatTimestamp(datum.Timestamp),
+		)
+	}
+
+	err := tw.Flush()
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(newBatch(namespace, data))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "%s\n", encoded)
+
+	return err
+}
+
+// formatValue of a datum, listing every value when it has many.
+func formatValue(datum types.MetricDatum) string {
+	if len(datum.Values) == 0 {
+		return fmt.Sprint(aws.ToFloat64(datum.Value))
+	}
+
+	values := make([]string, len(datum.Values))
+
+	for i, value := range datum.Values {
+		values[i] = fmt.Sprint(value)
+
+		if i < len(datum.Counts) && datum.Counts[i] != 1 {
+			values[i] += fmt.Sprintf("x%v", datum.Counts[i])
+		}
+	}
+
+	return "[" + strings.Join(values, " ") + "]"
+}
+
+// formatTimestamp of a datum, which CloudWatch sets when it is not given.
+func formatTimestamp(timestamp *time.Time) string {
+	if timestamp == nil {
+		return "-"
+	}
+
+	return timestamp.UTC().Format(time.RFC3339)
+}
+
+// orDash returns a dash in place of an empty value so that columns line up.
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
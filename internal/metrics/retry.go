@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// throttlingCodes are the error codes returned when requests are throttled.
+var throttlingCodes = []string{
+	"Throttling",
+	"ThrottlingException",
+	"ThrottledException",
+	"RequestThrottled",
+	"RequestThrottledException",
+	"TooManyRequestsException",
+	"RequestLimitExceeded",
+	"SlowDown",
+}
+
+// Retry policy for batches which fail to push.
+type Retry struct {
+	// MaxAttempts is how many times a batch is sent, including the first.
+	MaxAttempts int
+	// BaseDelay is the longest wait before the first retry, which doubles
+	// for each retry after it.
+	BaseDelay time.Duration
+	// MaxDelay caps the wait between retries.
+	MaxDelay time.Duration
+	// Budget is how many retries are allowed across every batch.
+	Budget int
+}
+
+// DefaultRetry policy for batches which fail to push.
+func DefaultRetry() Retry {
+	return Retry{
+		MaxAttempts: 5,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+		Budget:      20,
+	}
+}
+
+// Backoff before retrying after the given attempt, using exponential
+// backoff with full jitter so that concurrent clients spread out.
+func (r Retry) Backoff(attempt int) time.Duration {
+	delay := r.MaxDelay
+
+	if attempt < 32 && r.BaseDelay<<(attempt-1) < r.MaxDelay {
+		delay = r.BaseDelay << (attempt - 1)
+	}
+
+	if delay <= 0 {
+		return 0
+	}
+
+	return rand.N(delay + 1)
+}
+
+// Retryable reports whether the error is from throttling, a server error or
+// failing to send the request, which may succeed if sent again. The SDK does
+// not retry batches itself, so this covers the errors it would have.
+func Retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var sendErr *smithyhttp.RequestSendError
+
+	if errors.As(err, &sendErr) {
+		return true
+	}
+
+	var netErr net.Error
+
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	if retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err) == aws.TrueTernary {
+		return true
+	}
+
+	var apiErr smithy.APIError
+
+	if errors.As(err, &apiErr) {
+		if slices.Contains(throttlingCodes, apiErr.ErrorCode()) || apiErr.ErrorFault() == smithy.FaultServer {
+			return true
+		}
+	}
+
+	var respErr interface{ HTTPStatusCode() int }
+
+	if errors.As(err, &respErr) {
+		status := respErr.HTTPStatusCode()
+
+		return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+	}
+
+	return false
+}
+
+// Rejected reports whether CloudWatch refused the request because of a fault
+// with it, such as invalid data or denied permission, so it would be refused
+// again if sent later. Errors which never reached CloudWatch are not rejected.
+func Rejected(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || Retryable(err) {
+		return false
+	}
+
+	var apiErr smithy.APIError
+
+	if errors.As(err, &apiErr) && apiErr.ErrorFault() == smithy.FaultClient {
+		return true
+	}
+
+	var respErr interface{ HTTPStatusCode() int }
+
+	if errors.As(err, &respErr) {
+		status := respErr.HTTPStatusCode()
+
+		return status >= http.StatusBadRequest && status < http.StatusInternalServerError && status != http.StatusTooManyRequests
+	}
+
+	return false
+}
+
+// sleep for the duration, returning early if the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/assert"
+
+	client "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/cloudwatch"
+)
+
+var (
+	errThrottled = &smithy.GenericAPIError{Code: "Throttling", Message: "Rate exceeded", Fault: smithy.FaultClient}
+	errInvalid   = &smithy.GenericAPIError{Code: "InvalidParameterValue", Fault: smithy.FaultClient}
+	errServer    = &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusServiceUnavailable}},
+		Err:      errors.New("service unavailable"),
+	}
+	errSend = &smithyhttp.RequestSendError{Err: errors.New("dial tcp: lookup monitoring.ap-southeast-2.amazonaws.com: no such host")}
+)
+
+// newRetryClient which records the delays it sleeps for instead of sleeping.
+func newRetryClient(t *testing.T, cw *client.MockClient) (*Client, *[]time.Duration) {
+	c, err := New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	var delays []time.Duration
+
+	c.Sleep = func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return ctx.Err()
+	}
+
+	assert.NoError(t, c.Add(context.TODO(), types.MetricDatum{
+		MetricName: aws.String("TestResponse"),
+		Value:      aws.Float64(1),
+	}))
+
+	return c, &delays
+}
+
+func TestFlushRetry(t *testing.T) {
+	cw := &client.MockClient{
+		PutMetricDataErrors: []error{errThrottled, errServer},
+	}
+
+	c, delays := newRetryClient(t, cw)
+
+	assert.NoError(t, c.Flush(context.TODO()))
+	assert.Equal(t, 3, cw.PutMetricDataCalls)
+	assert.Len(t, cw.MetricData, 1)
+	assert.Empty(t, c.Data)
+
+	// Backoff is jittered within an exponentially growing cap.
+	assert.Len(t, *delays, 2)
+	assert.LessOrEqual(t, (*delays)[0], c.Retry.BaseDelay)
+	assert.LessOrEqual(t, (*delays)[1], 2*c.Retry.BaseDelay)
+}
+
+func TestFlushNotRetryable(t *testing.T) {
+	cw := &client.MockClient{
+		PutMetricDataErrors: []error{errInvalid},
+	}
+
+	c, delays := newRetryClient(t, cw)
+
+	assert.ErrorIs(t, c.Flush(context.TODO()), errInvalid)
+	assert.Equal(t, 1, cw.PutMetricDataCalls)
+	assert.Empty(t, *delays)
+
+	// The batch is kept so that it can be sent again.
+	assert.Len(t, c.Data, 1)
+}
+
+func TestFlushMaxAttempts(t *testing.T) {
+	cw := &client.MockClient{
+		PutMetricDataErrors: []error{errThrottled, errThrottled, errThrottled, errThrottled, errThrottled, errThrottled},
+	}
+
+	c, _ := newRetryClient(t, cw)
+
+	assert.ErrorIs(t, c.Flush(context.TODO()), errThrottled)
+	assert.Equal(t, c.Retry.MaxAttempts, cw.PutMetricDataCalls)
+	assert.Len(t, c.Data, 1)
+}
+
+func TestFlushRetryBudget(t *testing.T) {
+	cw := &client.MockClient{
+		PutMetricDataErrors: []error{errThrottled, nil, errThrottled, errThrottled},
+	}
+
+	c, _ := newRetryClient(t, cw)
+	c.Retry.Budget = 2
+
+	assert.NoError(t, c.Flush(context.TODO()))
+
+	assert.NoError(t, c.Add(context.TODO(), types.MetricDatum{
+		MetricName: aws.String("TestResponse"),
+		Value:      aws.Float64(1),
+	}))
+
+	// The budget is shared with the previous batch, so only one more retry is allowed.
+	err := c.Flush(context.TODO())
+	assert.ErrorIs(t, err, errThrottled)
+	assert.ErrorContains(t, err, "retry budget exhausted")
+	assert.Equal(t, 4, cw.PutMetricDataCalls)
+}
+
+func TestFlushContextCancelled(t *testing.T) {
+	cw := &client.MockClient{
+		PutMetricDataErrors: []error{errThrottled, errThrottled},
+	}
+
+	c, _ := newRetryClient(t, cw)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	cancel()
+
+	assert.ErrorIs(t, c.Flush(ctx), context.Canceled)
+	assert.Equal(t, 1, cw.PutMetricDataCalls)
+}
+
+func TestRetryable(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err       error
+		retryable bool
+	}{
+		"throttled": {err: errThrottled, retryable: true},
+		"server":    {err: errServer, retryable: true},
+		"internal":  {err: &smithy.GenericAPIError{Code: "InternalFailure", Fault: smithy.FaultServer}, retryable: true},
+		"send":      {err: errSend, retryable: true},
+		"timeout":   {err: &net.OpError{Op: "dial", Err: timeoutError{}}, retryable: true},
+		"reset":     {err: &smithyhttp.RequestSendError{Err: syscall.ECONNRESET}, retryable: true},
+		"invalid":   {err: errInvalid, retryable: false},
+		"canceled":  {err: context.Canceled, retryable: false},
+		"deadline":  {err: context.DeadlineExceeded, retryable: false},
+		"unknown":   {err: errors.New("unknown"), retryable: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.retryable, Retryable(tc.err))
+		})
+	}
+}
+
+func TestRejected(t *testing.T) {
+	for name, tc := range map[string]struct {
+		err      error
+		rejected bool
+	}{
+		"invalid":   {err: errInvalid, rejected: true},
+		"forbidden": {err: &smithyhttp.ResponseError{Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusForbidden}}, Err: errors.New("forbidden")}, rejected: true},
+		"throttled": {err: errThrottled, rejected: false},
+		"server":    {err: errServer, rejected: false},
+		"send":      {err: errSend, rejected: false},
+		"canceled":  {err: context.Canceled, rejected: false},
+		"deadline":  {err: context.DeadlineExceeded, rejected: false},
+		"unknown":   {err: errors.New("unknown"), rejected: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.rejected, Rejected(tc.err))
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	retry := Retry{
+		BaseDelay: time.Second,
+		MaxDelay:  5 * time.Second,
+	}
+
+	for attempt := 1; attempt < 100; attempt++ {
+		assert.LessOrEqual(t, retry.Backoff(attempt), retry.MaxDelay)
+		assert.GreaterOrEqual(t, retry.Backoff(attempt), time.Duration(0))
+	}
+}
+
+// timeoutError is a network error which timed out.
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
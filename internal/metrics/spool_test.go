@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/stretchr/testify/assert"
+
+	client "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/cloudwatch"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/clock"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/spool"
+)
+
+func TestFlushSpool(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 10, 5, 0, 0, time.UTC)
+
+	cw := &client.MockClient{
+		PutMetricDataErrors: []error{errThrottled},
+	}
+
+	store, err := spool.NewDirectoryStore(t.TempDir())
+	assert.NoError(t, err)
+
+	c, _ := newRetryClient(t, cw)
+	c.Retry.MaxAttempts = 1
+	c.Spool = store
+	c.Clock = clock.Mock{Time: now}
+
+	// The failed batch is spooled so the run can carry on.
+	assert.NoError(t, c.Flush(context.TODO()))
+	assert.Empty(t, c.Data)
+	assert.Empty(t, cw.MetricData)
+
+	entries, err := store.List(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "dev/null", entries[0].Namespace)
+	assert.True(t, now.Equal(entries[0].Created))
+	assert.Equal(t, "TestResponse", aws.ToString(entries[0].Data[0].MetricName))
+}
+
+func TestFlushSpoolDeadline(t *testing.T) {
+	cw := &client.MockClient{
+		PutMetricDataErrors: []error{context.DeadlineExceeded},
+	}
+
+	store, err := spool.NewDirectoryStore(t.TempDir())
+	assert.NoError(t, err)
+
+	c, _ := newRetryClient(t, cw)
+	c.Spool = store
+
+	// The batch may not have reached CloudWatch, so it is kept for a later
+	// run to replay.
+	assert.NoError(t, c.Flush(context.TODO()))
+
+	entries, err := store.List(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "TestResponse", aws.ToString(entries[0].Data[0].MetricName))
+}
+
+func TestFlushSpoolRejected(t *testing.T) {
+	cw := &client.MockClient{
+		PutMetricDataErrors: []error{errInvalid},
+	}
+
+	store, err := spool.NewDirectoryStore(t.TempDir())
+	assert.NoError(t, err)
+
+	c, _ := newRetryClient(t, cw)
+	c.Spool = store
+
+	// A batch which CloudWatch rejected would be rejected again, so the
+	// run fails instead of spooling it.
+	assert.ErrorIs(t, c.Flush(context.TODO()), errInvalid)
+
+	entries, err := store.List(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestReplay(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 10, 5, 0, 0, time.UTC)
+
+	store, err := spool.NewDirectoryStore(t.TempDir())
+	assert.NoError(t, err)
+
+	for _, entry := range []spool.Entry{
+		spool.NewEntry(now.Add(-3*time.Hour), "Skpr/CloudFront", []types.MetricDatum{
+			{MetricName: aws.String("Expired"), Value: aws.Float64(1), Timestamp: aws.Time(now.Add(-spool.MaxAge))},
+			{MetricName: aws.String("Fresh"), Value: aws.Float64(1), Timestamp: aws.Time(now.Add(-3 * time.Hour))},
+		}),
+		spool.NewEntry(now.Add(-2*time.Hour), "Skpr/CloudFront", []types.MetricDatum{
+			{MetricName: aws.String("Expired"), Value: aws.Float64(1), Timestamp: aws.Time(now.Add(-spool.MaxAge))},
+		}),
+		spool.NewEntry(now.Add(-90*time.Minute), "Skpr/CloudFront", []types.MetricDatum{
+			{MetricName: aws.String("Rejected"), Value: aws.Float64(1), Timestamp: aws.Time(now.Add(-90 * time.Minute))},
+		}),
+		spool.NewEntry(now.Add(-time.Hour), "Skpr/CloudFront", []types.MetricDatum{
+			{MetricName: aws.String("Failing"), Value: aws.Float64(1), Timestamp: aws.Time(now.Add(-time.Hour))},
+		}),
+	} {
+		assert.NoError(t, store.Put(context.TODO(), entry))
+	}
+
+	cw := &client.MockClient{
+		// The first entry succeeds, the second has nothing left to push,
+		// the third is rejected and the fourth fails.
+		PutMetricDataErrors: []error{nil, errInvalid, errThrottled},
+	}
+
+	c, err := New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	c.Retry.MaxAttempts = 1
+	c.Spool = store
+	c.Clock = clock.Mock{Time: now}
+
+	err = c.Replay(context.TODO())
+	assert.ErrorIs(t, err, errThrottled)
+
+	// Only metrics CloudWatch still accepts were pushed.
+	assert.Len(t, cw.MetricData, 1)
+	assert.Equal(t, "Fresh", aws.ToString(cw.MetricData[0].MetricName))
+
+	// The rejected entry is dropped, while the failed entry is left for
+	// the next run.
+	entries, err := store.List(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "Failing", aws.ToString(entries[0].Data[0].MetricName))
+
+	assert.NoError(t, c.Replay(context.TODO()))
+	assert.Len(t, cw.MetricData, 2)
+
+	entries, err = store.List(context.TODO())
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestReplayFailed(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 10, 5, 0, 0, time.UTC)
+
+	for name, tc := range map[string]struct {
+		err    error
+		cancel bool
+	}{
+		"cancelled": {err: context.Canceled, cancel: true},
+		"send":      {err: errSend},
+	} {
+		t.Run(name, func(t *testing.T) {
+			store, err := spool.NewDirectoryStore(t.TempDir())
+			assert.NoError(t, err)
+
+			assert.NoError(t, store.Put(context.TODO(), spool.NewEntry(now.Add(-time.Hour), "Skpr/CloudFront", []types.MetricDatum{
+				{MetricName: aws.String("Failing"), Value: aws.Float64(1), Timestamp: aws.Time(now.Add(-time.Hour))},
+			})))
+
+			cw := &client.MockClient{
+				PutMetricDataErrors: []error{tc.err},
+			}
+
+			c, err := New(cw, "dev/null", false)
+			assert.NoError(t, err)
+
+			c.Retry.MaxAttempts = 1
+			c.Spool = store
+			c.Clock = clock.Mock{Time: now}
+
+			ctx, cancel := context.WithCancel(context.TODO())
+			defer cancel()
+
+			if tc.cancel {
+				cancel()
+			}
+
+			assert.ErrorIs(t, c.Replay(ctx), tc.err)
+
+			// The batch may not have reached CloudWatch, so it is left for
+			// the next run.
+			entries, err := store.List(context.TODO())
+			assert.NoError(t, err)
+			assert.Len(t, entries, 1)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package paths
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	// Wildcard is the character CloudFront accepts at the end of a path to
+	// invalidate every object which starts with it.
+	Wildcard = "*"
+	// FullPurge is the path which invalidates every object in a distribution.
+	FullPurge = "/*"
+)
+
+// IsWildcard reports whether an invalidation path contains a wildcard.
+func IsWildcard(path string) bool {
+	return strings.HasSuffix(path, Wildcard)
+}
+
+// IsFullPurge reports whether an invalidation path invalidates every object.
+func IsFullPurge(path string) bool {
+	return path == FullPurge
+}
+
+// OtherGroup is the name of the group for paths which match no prefix.
+const OtherGroup = "Other"
+
+// Group of paths which share a prefix.
+type Group struct {
+	Name   string
+	Prefix string
+}
+
+// Groups classifies paths by their prefix.
+type Groups []Group
+
+// ParseGroups from a comma separated list of name=prefix pairs, such as
+// "API=/api,Static=/static,Static=/assets". A name can be given more than
+// once to group several prefixes together.
+func ParseGroups(value string) (Groups, error) {
+	var groups Groups
+
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, prefix, ok := strings.Cut(pair, "=")
+		if !ok || name == "" || prefix == "" {
+			return nil, fmt.Errorf("invalid path group: %q", pair)
+		}
+
+		groups = append(groups, Group{
+			Name:   name,
+			Prefix: prefix,
+		})
+	}
+
+	return groups, nil
+}
+
+// Classify a path by the group with the longest matching prefix, or
+// OtherGroup when no prefix matches.
+func (g Groups) Classify(path string) string {
+	var match Group
+
+	for _, group := range g {
+		if strings.HasPrefix(path, group.Prefix) && len(group.Prefix) > len(match.Prefix) {
+			match = group
+		}
+	}
+
+	if match.Name == "" {
+		return OtherGroup
+	}
+
+	return match.Name
+}
+
+// Names of every group a path can be classified by, including OtherGroup.
+func (g Groups) Names() []string {
+	var names []string
+
+	for _, group := range g {
+		if !slices.Contains(names, group.Name) {
+			names = append(names, group.Name)
+		}
+	}
+
+	if !slices.Contains(names, OtherGroup) {
+		names = append(names, OtherGroup)
+	}
+
+	return names
+}
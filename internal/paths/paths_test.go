@@ -0,0 +1,52 @@
+package paths
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsWildcard(t *testing.T) {
+	assert.True(t, IsWildcard("/*"))
+	assert.True(t, IsWildcard("/images/*"))
+	assert.True(t, IsWildcard("/images/logo*"))
+	assert.False(t, IsWildcard("/images/logo.png"))
+}
+
+func TestIsFullPurge(t *testing.T) {
+	assert.True(t, IsFullPurge("/*"))
+	assert.False(t, IsFullPurge("/images/*"))
+	assert.False(t, IsFullPurge("/"))
+}
+
+func TestParseGroups(t *testing.T) {
+	groups, err := ParseGroups("API=/api, Static=/static,Static=/assets")
+	assert.NoError(t, err)
+	assert.Equal(t, Groups{
+		{Name: "API", Prefix: "/api"},
+		{Name: "Static", Prefix: "/static"},
+		{Name: "Static", Prefix: "/assets"},
+	}, groups)
+
+	groups, err = ParseGroups("")
+	assert.NoError(t, err)
+	assert.Empty(t, groups)
+
+	_, err = ParseGroups("API")
+	assert.Error(t, err)
+}
+
+func TestGroupsClassify(t *testing.T) {
+	groups := Groups{
+		{Name: "Media", Prefix: "/media"},
+		{Name: "Thumbnails", Prefix: "/media/thumbnails"},
+		{Name: "Static", Prefix: "/static"},
+	}
+
+	assert.Equal(t, "Media", groups.Classify("/media/video.mp4"))
+	assert.Equal(t, "Thumbnails", groups.Classify("/media/thumbnails/*"))
+	assert.Equal(t, "Static", groups.Classify("/static/*"))
+	assert.Equal(t, OtherGroup, groups.Classify("/*"))
+
+	assert.Equal(t, []string{"Media", "Thumbnails", "Static", OtherGroup}, groups.Names())
+}
@@ -0,0 +1,78 @@
+package sources
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+const (
+	// UnknownSource is the label for caller references which match no rule.
+	UnknownSource = "Unknown"
+	// SourceGroup is the name of the capture group a rule can use to extract
+	// the label from the caller reference.
+	SourceGroup = "source"
+)
+
+// Rule which labels caller references matching a pattern.
+type Rule struct {
+	// Name to label matching caller references with. When empty the label
+	// is extracted from the "source" capture group of the pattern.
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+
+	regexp *regexp.Regexp
+}
+
+// Rules label caller references by the first rule which matches.
+type Rules []Rule
+
+// ParseRules from a JSON array, such as:
+//
+//	[{"name": "CMS", "pattern": "^cms-"}, {"pattern": "^ci-(?P<source>[a-z]+)-"}]
+func ParseRules(value string) (Rules, error) {
+	var rules Rules
+
+	if value == "" {
+		return rules, nil
+	}
+
+	err := json.Unmarshal([]byte(value), &rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode rules: %w", err)
+	}
+
+	for i, rule := range rules {
+		rules[i].regexp, err = regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile pattern: %q: %w", rule.Pattern, err)
+		}
+
+		if rule.Name == "" && rules[i].regexp.SubexpIndex(SourceGroup) == -1 {
+			return nil, fmt.Errorf("pattern requires a name or a %q capture group: %q", SourceGroup, rule.Pattern)
+		}
+	}
+
+	return rules, nil
+}
+
+// Match a caller reference to a source label, or UnknownSource when no
+// rule matches.
+func (r Rules) Match(reference string) string {
+	for _, rule := range r {
+		match := rule.regexp.FindStringSubmatch(reference)
+		if match == nil {
+			continue
+		}
+
+		if rule.Name != "" {
+			return rule.Name
+		}
+
+		if source := match[rule.regexp.SubexpIndex(SourceGroup)]; source != "" {
+			return source
+		}
+	}
+
+	return UnknownSource
+}
@@ -0,0 +1,36 @@
+package sources
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules("")
+	assert.NoError(t, err)
+	assert.Empty(t, rules)
+
+	_, err = ParseRules(`[{"pattern": "^cms-"}]`)
+	assert.Error(t, err)
+
+	_, err = ParseRules(`[{"name": "CMS", "pattern": "^cms-("}]`)
+	assert.Error(t, err)
+
+	_, err = ParseRules(`{}`)
+	assert.Error(t, err)
+}
+
+func TestRulesMatch(t *testing.T) {
+	rules, err := ParseRules(`[
+		{"name": "CMS", "pattern": "^drupal-"},
+		{"pattern": "^ci-(?P<source>[a-z]+)-[0-9]+$"},
+		{"name": "Console", "pattern": "^[0-9a-f-]{36}$"}
+	]`)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "CMS", rules.Match("drupal-1700000000"))
+	assert.Equal(t, "github", rules.Match("ci-github-123"))
+	assert.Equal(t, "Console", rules.Match("2f1c1b6e-6a5b-4b8e-9c3e-0e6e8f1d2a3b"))
+	assert.Equal(t, UnknownSource, rules.Match("manual"))
+}
@@ -0,0 +1,115 @@
+package spool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// DirectoryStore persists entries as JSON files in a local directory, such
+// as /tmp in Lambda.
+type DirectoryStore struct {
+	Path string
+}
+
+// NewDirectoryStore which stores entries in the given directory, creating
+// it when it does not exist.
+func NewDirectoryStore(path string) (*DirectoryStore, error) {
+	err := os.MkdirAll(path, 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	return &DirectoryStore{
+		Path: path,
+	}, nil
+}
+
+// Put an entry in the spool. The file is written atomically so an
+// interrupted run cannot leave a partial entry behind.
+func (s *DirectoryStore) Put(ctx context.Context, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode entry: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.Path, entry.ID+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write entry: %w", err)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write entry: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), s.file(entry.ID))
+}
+
+// List every entry in the spool, oldest first.
+func (s *DirectoryStore) List(ctx context.Context) ([]Entry, error) {
+	files, err := os.ReadDir(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	var ids []string
+
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), Extension) {
+			ids = append(ids, strings.TrimSuffix(file.Name(), Extension))
+		}
+	}
+
+	slices.Sort(ids)
+
+	entries := make([]Entry, 0, len(ids))
+
+	for _, id := range ids {
+		data, err := os.ReadFile(s.file(id))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read entry: %w", err)
+		}
+
+		var entry Entry
+
+		err = json.Unmarshal(data, &entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode entry: %s: %w", id, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// Delete an entry from the spool.
+func (s *DirectoryStore) Delete(ctx context.Context, id string) error {
+	err := os.Remove(s.file(id))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete entry: %w", err)
+	}
+
+	return nil
+}
+
+// file which an entry is stored in.
+func (s *DirectoryStore) file(id string) string {
+	return filepath.Join(s.Path, id+Extension)
+}
@@ -0,0 +1,129 @@
+package spool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	s3client "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/s3"
+)
+
+// S3Store persists entries as JSON objects in an S3 compatible bucket.
+type S3Store struct {
+	S3     s3client.ClientInterface
+	Bucket string
+	// Prefix added to the key of every entry.
+	Prefix string
+}
+
+// NewS3Store which stores entries in the given bucket under the prefix.
+func NewS3Store(client s3client.ClientInterface, bucket, prefix string) (*S3Store, error) {
+	return &S3Store{
+		S3:     client,
+		Bucket: bucket,
+		Prefix: prefix,
+	}, nil
+}
+
+// Put an entry in the spool.
+func (s *S3Store) Put(ctx context.Context, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode entry: %w", err)
+	}
+
+	_, err = s.S3.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(s.key(entry.ID)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put entry: %w", err)
+	}
+
+	return nil
+}
+
+// List every entry in the spool, oldest first. Keys are listed in order
+// so entries are too.
+func (s *S3Store) List(ctx context.Context) ([]Entry, error) {
+	var entries []Entry
+
+	paginator := s3.NewListObjectsV2Paginator(s.S3, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(s.Prefix),
+	})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list entries: %w", err)
+		}
+
+		for _, object := range page.Contents {
+			if !strings.HasSuffix(aws.ToString(object.Key), Extension) {
+				continue
+			}
+
+			entry, err := s.get(ctx, aws.ToString(object.Key))
+			if err != nil {
+				return nil, err
+			}
+
+			entries = append(entries, entry)
+		}
+	}
+
+	return entries, nil
+}
+
+// Delete an entry from the spool.
+func (s *S3Store) Delete(ctx context.Context, id string) error {
+	_, err := s.S3.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(s.key(id)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete entry: %w", err)
+	}
+
+	return nil
+}
+
+// get the entry stored in an object.
+func (s *S3Store) get(ctx context.Context, key string) (Entry, error) {
+	var entry Entry
+
+	output, err := s.S3.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return entry, fmt.Errorf("failed to get entry: %s: %w", key, err)
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return entry, fmt.Errorf("failed to read entry: %s: %w", key, err)
+	}
+
+	err = json.Unmarshal(data, &entry)
+	if err != nil {
+		return entry, fmt.Errorf("failed to decode entry: %s: %w", key, err)
+	}
+
+	return entry, nil
+}
+
+// key of the object which an entry is stored in.
+func (s *S3Store) key(id string) string {
+	return s.Prefix + id + Extension
+}
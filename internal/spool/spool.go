@@ -0,0 +1,74 @@
+package spool
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+const (
+	// MaxAge is how old a metric can be before CloudWatch no longer
+	// accepts it.
+	MaxAge = 14 * 24 * time.Hour
+	// Extension of the files and objects which entries are stored in.
+	Extension = ".json"
+)
+
+// StoreInterface for persisting batches which failed to push, so they can
+// be replayed by a later run.
+type StoreInterface interface {
+	// Put an entry in the spool.
+	Put(ctx context.Context, entry Entry) error
+	// List every entry in the spool, oldest first.
+	List(ctx context.Context) ([]Entry, error)
+	// Delete an entry from the spool.
+	Delete(ctx context.Context, id string) error
+}
+
+// Entry for a batch which failed to push.
+type Entry struct {
+	// ID which sorts entries by when they were created.
+	ID        string              `json:"id"`
+	Created   time.Time           `json:"created"`
+	Namespace string              `json:"namespace"`
+	Data      []types.MetricDatum `json:"data"`
+}
+
+// NewEntry for a batch which failed to push at the given time.
+func NewEntry(created time.Time, namespace string, data []types.MetricDatum) Entry {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+
+	return Entry{
+		ID:        created.UTC().Format("20060102T150405.000000000Z") + "-" + hex.EncodeToString(suffix),
+		Created:   created,
+		Namespace: namespace,
+		Data:      data,
+	}
+}
+
+// Expire removes metrics which are too old for CloudWatch to accept at the
+// given time. Metrics without a timestamp are as old as the entry.
+func (e Entry) Expire(now time.Time) Entry {
+	cutoff := now.Add(-MaxAge)
+
+	var data []types.MetricDatum
+
+	for _, datum := range e.Data {
+		timestamp := e.Created
+		if datum.Timestamp != nil {
+			timestamp = *datum.Timestamp
+		}
+
+		if timestamp.After(cutoff) {
+			data = append(data, datum)
+		}
+	}
+
+	e.Data = data
+
+	return e
+}
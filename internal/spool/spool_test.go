@@ -0,0 +1,96 @@
+package spool
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/stretchr/testify/assert"
+
+	s3client "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/s3"
+)
+
+func testStore(t *testing.T, store StoreInterface) {
+	ctx := context.TODO()
+
+	now := time.Date(2024, time.March, 1, 10, 5, 0, 0, time.UTC)
+
+	entries, err := store.List(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+
+	second := NewEntry(now.Add(time.Minute), "Skpr/CloudFront", []types.MetricDatum{
+		{MetricName: aws.String("InvalidationRequest"), Value: aws.Float64(2), Timestamp: aws.Time(now)},
+	})
+	first := NewEntry(now, "Skpr/CloudFront", []types.MetricDatum{
+		{MetricName: aws.String("InvalidationRequest"), Value: aws.Float64(1), Timestamp: aws.Time(now)},
+	})
+
+	assert.NoError(t, store.Put(ctx, second))
+	assert.NoError(t, store.Put(ctx, first))
+
+	// Entries are listed oldest first.
+	entries, err = store.List(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, first.ID, entries[0].ID)
+	assert.Equal(t, "Skpr/CloudFront", entries[0].Namespace)
+	assert.Equal(t, float64(1), aws.ToFloat64(entries[0].Data[0].Value))
+	assert.True(t, now.Equal(aws.ToTime(entries[0].Data[0].Timestamp)))
+	assert.Equal(t, second.ID, entries[1].ID)
+
+	assert.NoError(t, store.Delete(ctx, first.ID))
+
+	entries, err = store.List(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, second.ID, entries[0].ID)
+}
+
+func TestDirectoryStore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "spool")
+
+	store, err := NewDirectoryStore(dir)
+	assert.NoError(t, err)
+
+	testStore(t, store)
+
+	// Temporary files from interrupted writes are ignored.
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "partial.json.123.tmp"), []byte("{"), 0o644))
+
+	entries, err := store.List(context.TODO())
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestS3Store(t *testing.T) {
+	client := &s3client.MockClient{}
+
+	store, err := NewS3Store(client, "bucket", "spool/")
+	assert.NoError(t, err)
+
+	testStore(t, store)
+
+	for key := range client.Objects["bucket"] {
+		assert.Regexp(t, `^spool/.+\.json$`, key)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	now := time.Date(2024, time.March, 15, 10, 5, 0, 0, time.UTC)
+
+	entry := NewEntry(now.Add(-MaxAge-time.Hour), "Skpr/CloudFront", []types.MetricDatum{
+		{MetricName: aws.String("Expired"), Timestamp: aws.Time(now.Add(-MaxAge))},
+		{MetricName: aws.String("Fresh"), Timestamp: aws.Time(now.Add(-MaxAge + time.Minute))},
+		// Without a timestamp it is as old as the entry.
+		{MetricName: aws.String("Untimed")},
+	})
+
+	entry = entry.Expire(now)
+	assert.Len(t, entry.Data, 1)
+	assert.Equal(t, "Fresh", aws.ToString(entry.Data[0].MetricName))
+}
@@ -0,0 +1,83 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	dynamodbclient "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/dynamodb"
+)
+
+const (
+	// DynamoDBKeyAttribute is the partition key of the state table.
+	DynamoDBKeyAttribute = "Key"
+	// DynamoDBStateAttribute holds the JSON encoded state.
+	DynamoDBStateAttribute = "State"
+)
+
+// DynamoDBStore persists state as items in a DynamoDB table.
+type DynamoDBStore struct {
+	DynamoDB dynamodbclient.ClientInterface
+	Table    string
+}
+
+// NewDynamoDBStore which reads and writes state to the given table.
+func NewDynamoDBStore(client dynamodbclient.ClientInterface, table string) (*DynamoDBStore, error) {
+	return &DynamoDBStore{
+		DynamoDB: client,
+		Table:    table,
+	}, nil
+}
+
+// Get the state stored against a key.
+func (s *DynamoDBStore) Get(ctx context.Context, key string) (State, error) {
+	var state State
+
+	output, err := s.DynamoDB.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(s.Table),
+		Key: map[string]types.AttributeValue{
+			DynamoDBKeyAttribute: &types.AttributeValueMemberS{Value: key},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return state, fmt.Errorf("failed to get state item: %w", err)
+	}
+
+	value, ok := output.Item[DynamoDBStateAttribute].(*types.AttributeValueMemberS)
+	if !ok {
+		return state, nil
+	}
+
+	err = json.Unmarshal([]byte(value.Value), &state)
+	if err != nil {
+		return state, fmt.Errorf("failed to decode state item: %w", err)
+	}
+
+	return state, nil
+}
+
+// Put the state against a key.
+func (s *DynamoDBStore) Put(ctx context.Context, key string, state State) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode state item: %w", err)
+	}
+
+	_, err = s.DynamoDB.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.Table),
+		Item: map[string]types.AttributeValue{
+			DynamoDBKeyAttribute:   &types.AttributeValueMemberS{Value: key},
+			DynamoDBStateAttribute: &types.AttributeValueMemberS{Value: string(data)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put state item: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStore persists state as a JSON document on the local filesystem.
+type FileStore struct {
+	mu   sync.Mutex
+	Path string
+}
+
+// NewFileStore which reads and writes state to the given path.
+func NewFileStore(path string) (*FileStore, error) {
+	return &FileStore{
+		Path: path,
+	}, nil
+}
+
+// Get the state stored against a key.
+func (s *FileStore) Get(ctx context.Context, key string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states, err := s.read()
+	if err != nil {
+		return State{}, err
+	}
+
+	return states[key], nil
+}
+
+// Put the state against a key.
+func (s *FileStore) Put(ctx context.Context, key string, state State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	states[key] = state
+
+	return s.write(states)
+}
+
+// read every state from the file, callers must hold the lock.
+func (s *FileStore) read() (map[string]State, error) {
+	states := make(map[string]State)
+
+	data, err := os.ReadFile(s.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file: %w", err)
+	}
+
+	err = json.Unmarshal(data, &states)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode state file: %w", err)
+	}
+
+	return states, nil
+}
+
+// write every state to the file, callers must hold the lock. The file is
+// replaced atomically so an interrupted run cannot corrupt it.
+func (s *FileStore) write(states map[string]State) error {
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state file: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.Path), filepath.Base(s.Path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create state file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return fmt.Errorf("failed to write state file: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), s.Path)
+}
@@ -0,0 +1,90 @@
+package state
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// StoreInterface for persisting state between runs.
+type StoreInterface interface {
+	// Get the state stored against a key. A zero State is returned
+	// when nothing has been stored yet.
+	Get(ctx context.Context, key string) (State, error)
+	// Put the state against a key, replacing anything stored before.
+	Put(ctx context.Context, key string, state State) error
+}
+
+// State which is carried between runs for a distribution.
+type State struct {
+	// Checkpoint marks the most recent invalidation which has been counted.
+	Checkpoint Checkpoint `json:"checkpoint"`
+	// InProgress are the IDs of invalidations which were in progress.
+	InProgress []string `json:"inProgress,omitempty"`
+	// Month which paths are being accumulated for, such as "2024-03".
+	Month string `json:"month,omitempty"`
+	// MonthPaths is how many paths have been invalidated during the Month.
+	MonthPaths float64 `json:"monthPaths,omitempty"`
+}
+
+// Checkpoint marks where the previous successful run stopped counting.
+type Checkpoint struct {
+	// InvalidationID of the most recent invalidation counted. Empty when
+	// no invalidation has been counted yet.
+	InvalidationID string `json:"invalidationId,omitempty"`
+	// CreateTime of the most recent invalidation counted. When no invalidation
+	// has been counted yet, the time from which invalidations are included.
+	CreateTime time.Time `json:"createTime"`
+	// InvalidationIDs of every invalidation counted which was created at
+	// CreateTime, as create times are only precise to the second.
+	InvalidationIDs []string `json:"invalidationIds,omitempty"`
+}
+
+// IsZero reports whether the checkpoint has never been set.
+func (c Checkpoint) IsZero() bool {
+	return c.InvalidationID == "" && c.CreateTime.IsZero()
+}
+
+// Includes reports whether an invalidation was created after the checkpoint,
+// or at the same time but has not been counted.
+func (c Checkpoint) Includes(invalidationID string, createTime time.Time) bool {
+	switch {
+	case createTime.After(c.CreateTime):
+		return true
+	case createTime.Before(c.CreateTime):
+		return false
+	}
+
+	return !slices.Contains(c.counted(), invalidationID)
+}
+
+// Advance the checkpoint past an invalidation which has been counted, which
+// must not have been created before the checkpoint.
+func (c Checkpoint) Advance(invalidationID string, createTime time.Time) Checkpoint {
+	next := Checkpoint{
+		InvalidationID:  invalidationID,
+		CreateTime:      createTime,
+		InvalidationIDs: []string{invalidationID},
+	}
+
+	// Invalidations counted at the same time are kept, as they would
+	// otherwise be counted again.
+	if createTime.Equal(c.CreateTime) {
+		next.InvalidationIDs = append(c.counted(), invalidationID)
+	}
+
+	return next
+}
+
+// counted are the IDs of the invalidations counted which were created at the
+// checkpoint's CreateTime. Checkpoints stored by earlier versions only have
+// the InvalidationID.
+func (c Checkpoint) counted() []string {
+	ids := slices.Clone(c.InvalidationIDs)
+
+	if c.InvalidationID != "" && !slices.Contains(ids, c.InvalidationID) {
+		ids = append(ids, c.InvalidationID)
+	}
+
+	return ids
+}
@@ -0,0 +1,99 @@
+package state
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	dynamodbclient "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/dynamodb"
+)
+
+func TestCheckpointIncludes(t *testing.T) {
+	now := time.Now()
+
+	window := Checkpoint{CreateTime: now}
+	assert.True(t, window.Includes("a", now))
+	assert.False(t, window.Includes("a", now.Add(-time.Second)))
+
+	checkpoint := Checkpoint{InvalidationID: "a", CreateTime: now}
+	assert.False(t, checkpoint.Includes("a", now))
+	assert.True(t, checkpoint.Includes("b", now))
+	assert.False(t, checkpoint.Includes("b", now.Add(-time.Second)))
+}
+
+func TestCheckpointAdvance(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 10, 5, 0, 0, time.UTC)
+
+	// Two invalidations are counted which share a create time.
+	checkpoint := Checkpoint{CreateTime: now.Add(-time.Minute)}.
+		Advance("a", now).
+		Advance("b", now)
+
+	assert.Equal(t, "b", checkpoint.InvalidationID)
+	assert.False(t, checkpoint.Includes("a", now))
+	assert.False(t, checkpoint.Includes("b", now))
+	assert.True(t, checkpoint.Includes("c", now))
+
+	// Another created at the same time is counted by a later run.
+	checkpoint = checkpoint.Advance("c", now)
+	assert.False(t, checkpoint.Includes("a", now))
+	assert.False(t, checkpoint.Includes("c", now))
+
+	// Those created earlier are forgotten once a newer one is counted.
+	checkpoint = checkpoint.Advance("d", now.Add(time.Second))
+	assert.Equal(t, []string{"d"}, checkpoint.InvalidationIDs)
+	assert.False(t, checkpoint.Includes("a", now))
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+	assert.NoError(t, err)
+
+	testStore(t, store)
+
+	// A new store should read back what the previous one wrote.
+	reopened, err := NewFileStore(store.Path)
+	assert.NoError(t, err)
+
+	state, err := reopened.Get(context.TODO(), "dist-a")
+	assert.NoError(t, err)
+	assert.Equal(t, "inv-2", state.Checkpoint.InvalidationID)
+}
+
+func TestDynamoDBStore(t *testing.T) {
+	store, err := NewDynamoDBStore(&dynamodbclient.MockClient{
+		KeyAttribute: DynamoDBKeyAttribute,
+	}, "state")
+	assert.NoError(t, err)
+
+	testStore(t, store)
+}
+
+func testStore(t *testing.T, store StoreInterface) {
+	ctx := context.TODO()
+
+	state, err := store.Get(ctx, "dist-a")
+	assert.NoError(t, err)
+	assert.True(t, state.Checkpoint.IsZero())
+
+	createTime := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, id := range []string{"inv-1", "inv-2"} {
+		err = store.Put(ctx, "dist-a", State{
+			Checkpoint: Checkpoint{InvalidationID: id, CreateTime: createTime},
+		})
+		assert.NoError(t, err)
+	}
+
+	state, err = store.Get(ctx, "dist-a")
+	assert.NoError(t, err)
+	assert.Equal(t, "inv-2", state.Checkpoint.InvalidationID)
+	assert.True(t, createTime.Equal(state.Checkpoint.CreateTime))
+
+	state, err = store.Get(ctx, "dist-b")
+	assert.NoError(t, err)
+	assert.True(t, state.Checkpoint.IsZero())
+}
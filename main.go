@@ -3,18 +3,26 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
+	"log"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
-	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
 
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/alarms"
 	cloudfrontclient "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/cloudfront"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/billing"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/clock"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/dashboard"
 	"github.com/skpr/cloudfront-invalidation-metrics/internal/metrics"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/state"
 )
 
 const (
@@ -25,106 +33,235 @@ const (
 // Start is an exported abstraction so that the application can be
 // setup in a way that works for you, opposed to being a tightly
 // coupled to provided and assumed Clients.
-func Start(ctx context.Context) error {
+func Start(ctx context.Context, event events.EventBridgeEvent) error {
 	cfg, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get AWS client: %w", err)
 	}
 
-	dryRun := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_DRYRUN") != ""
+	options, err := optionsFromEnv(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load options: %w", err)
+	}
+
+	accounts, err := accountsFromEnv(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load accounts: %w", err)
+	}
 
-	client, err := metrics.New(cloudwatch.NewFromConfig(cfg), CloudWatchNamespace, dryRun)
+	client, err := clientFromEnv(cfg, options)
 	if err != nil {
 		return fmt.Errorf("failed to setup client: %w", err)
 	}
 
-	return Execute(ctx, cloudfront.NewFromConfig(cfg), client)
+	// Some sinks hold a connection open which is closed once pushed.
+	if closer, ok := client.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	// Batches which failed to push on previous runs are replayed first. A
+	// failure leaves them spooled for the next run rather than holding up
+	// this one.
+	if spooled, ok := client.(interface{ Replay(context.Context) error }); ok {
+		err = spooled.Replay(ctx)
+		if err != nil {
+			log.Printf("failed to replay spooled metrics: %s", err)
+		}
+	}
+
+	return ExecuteAccounts(ctx, accounts, client, event.Time, options)
 }
 
 // Execute will execute the given API calls against the input Clients.
-func Execute(ctx context.Context, clientCloudFront cloudfrontclient.ClientInterface, client metrics.ClientInterface) error {
-	distributions, err := clientCloudFront.ListDistributions(ctx, &cloudfront.ListDistributionsInput{})
+//
+// The scheduled time is when the run was scheduled to execute and marks the
+// end of the window being measured. When zero the time is taken from the
+// clock in the options.
+func Execute(ctx context.Context, clientCloudFront cloudfrontclient.ClientInterface, client metrics.ClientInterface, scheduled time.Time, options Options) error {
+	distributions, err := listDistributions(ctx, clientCloudFront)
 	if err != nil {
 		return fmt.Errorf("failed to get CloudFront distibution list: %w", err)
 	}
 
-	// FiveMinutesAgo is a variable storing time. It will be used to make a
-	// time comparison between the time an invalidation was created and
-	// five minutes ago, which is the fixed input time which this lambda
-	// is intended to execute.
-	fiveMinutesAgo := time.Now().Add(time.Minute * -5)
+	if scheduled.IsZero() {
+		clk := options.Clock
+		if clk == nil {
+			clk = clock.Real{}
+		}
+
+		scheduled = clk.Now()
+	}
+
+	period := options.Period
+	if period <= 0 {
+		period = DefaultPeriod
+	}
+
+	// Window is the period of time being measured. Metrics are timestamped
+	// with its end so they line up with the schedule regardless of how
+	// late this run started.
+	window := newWindow(scheduled, period)
 
-	for _, distribution := range distributions.DistributionList.Items {
-		invalidations, err := clientCloudFront.ListInvalidations(ctx, &cloudfront.ListInvalidationsInput{
-			DistributionId: distribution.Id,
-		})
+	pricing := options.Pricing
+	if pricing == (billing.Pricing{}) {
+		pricing = billing.DefaultPricing()
+	}
+
+	lookback := options.Lookback
+	if lookback == 0 {
+		lookback = DefaultLookback
+	}
+
+	pathQuota := options.PathQuota
+	if pathQuota == 0 {
+		pathQuota = DefaultPathQuota
+	}
+
+	wildcardQuota := options.WildcardQuota
+	if wildcardQuota == 0 {
+		wildcardQuota = DefaultWildcardQuota
+	}
+
+	c := collector{
+		CloudFront:    clientCloudFront,
+		Store:         options.Store,
+		Window:        window,
+		Lookback:      lookback,
+		PathGroups:    options.PathGroups,
+		Sources:       options.Sources,
+		TagDimensions: options.TagDimensions,
+		NameDimension: options.NameDimension,
+		AccountID:     options.AccountID,
+		PathQuota:     pathQuota,
+		WildcardQuota: wildcardQuota,
+	}
+
+	results, err := c.collectAll(ctx, distributions, options.Concurrency)
+	if err != nil {
+		return err
+	}
+
+	var data []types.MetricDatum
+
+	// Month to date billing can only be tracked when state is stored.
+	var account state.State
+
+	if options.Store != nil {
+		account, err = accountBilling(ctx, options.Store, options.AccountID, window, pricing, results)
 		if err != nil {
-			return fmt.Errorf("failed to list invalidations: %w", err)
+			return err
 		}
 
-		var (
-			countInvalidations float64
-			countPaths         float64
-		)
+		data = append(data, accountDatums(account, options.AccountID, pricing, window.End)...)
+	}
 
-		for _, invalidation := range invalidations.InvalidationList.Items {
+	for _, result := range results {
+		data = append(data, result.datums(window.End)...)
+	}
 
-			if !fiveMinutesAgo.Before(*invalidation.CreateTime) {
-				break
-			}
+	for _, datum := range data {
+		err = client.Add(ctx, datum)
+		if err != nil {
+			return fmt.Errorf("failed to push metric: %s: %w", aws.ToString(datum.MetricName), err)
+		}
+	}
+
+	err = client.Flush(ctx)
+	if err != nil {
+		return err
+	}
 
-			// Include Invalidation in count as the timeframe is acceptable.
-			countInvalidations++
+	// Nothing is changed by a dry run, so the next real run publishes the
+	// same invalidations.
+	if options.DryRun {
+		return nil
+	}
 
-			invalidationDetail, err := clientCloudFront.GetInvalidation(ctx, &cloudfront.GetInvalidationInput{
-				DistributionId: distribution.Id,
-				Id:             invalidation.Id,
-			})
+	// Checkpoints are only stored once the metrics have been pushed so
+	// that a failed run is counted again by the next one.
+	if options.Store != nil {
+		for _, result := range results {
+			err = options.Store.Put(ctx, result.DistributionID, result.State)
 			if err != nil {
-				return fmt.Errorf("failed to get invalidation detail: %w", err)
+				return fmt.Errorf("failed to put state: %w", err)
 			}
+		}
+
+		err = options.Store.Put(ctx, accountStateKey(options.AccountID), account)
+		if err != nil {
+			return fmt.Errorf("failed to put account state: %w", err)
+		}
+	}
 
-			if invalidationDetail != nil {
-				countPaths = countPaths + float64(*invalidationDetail.Invalidation.InvalidationBatch.Paths.Quantity)
+	if options.Alarms != nil {
+		targets := make([]alarms.Target, len(results))
+
+		for i, result := range results {
+			targets[i] = alarms.Target{
+				Name:       result.DistributionID,
+				Dimensions: result.dimensions(),
 			}
 		}
 
-		err = client.Add(types.MetricDatum{
-			MetricName: aws.String("InvalidationRequest"),
-			Unit:       types.StandardUnitCount,
-			Value:      aws.Float64(countInvalidations),
-			Timestamp:  aws.Time(time.Now()),
-			Dimensions: []types.Dimension{
-				{
-					Name:  aws.String("Distribution"),
-					Value: aws.String(*distribution.Id),
-				},
-			},
-		})
+		// Alarms for distributions which no longer exist are deleted, so
+		// each account is reconciled under its own prefix.
+		reconciler := *options.Alarms
+
+		if options.AccountID != "" {
+			reconciler.Prefix += options.AccountID + "-"
+		}
+
+		// Metrics have already been pushed, so failing the run would only
+		// cause them to be pushed again when it is retried.
+		err = reconciler.Reconcile(ctx, targets)
 		if err != nil {
-			return fmt.Errorf("failed to push metric: InvalidationRequest: %w", err)
+			log.Printf("failed to reconcile alarms: %v", err)
+		}
+	}
+
+	if options.Dashboard != nil {
+		targets := make([]dashboard.Target, len(results))
+
+		for i, result := range results {
+			targets[i] = dashboard.Target{
+				Name:       result.DistributionID,
+				Dimensions: result.dimensions(),
+			}
+		}
+
+		// Regenerated every run so distributions which appear or disappear
+		// are kept in sync, with a dashboard for each account.
+		d := *options.Dashboard
+
+		if options.AccountID != "" {
+			d.Name += "-" + options.AccountID
+			d.AccountDimensions = accountDimensions(options.AccountID)
 		}
 
-		err = client.Add(types.MetricDatum{
-			MetricName: aws.String("InvalidationPathCounter"),
-			Unit:       types.StandardUnitCount,
-			Value:      aws.Float64(countPaths),
-			Timestamp:  aws.Time(time.Now()),
-			Dimensions: []types.Dimension{
-				{
-					Name:  aws.String("Distribution"),
-					Value: aws.String(*distribution.Id),
-				},
-			},
-		})
+		err = d.Apply(ctx, targets)
 		if err != nil {
-			return fmt.Errorf("failed to push metric: InvalidationPathCounter: %w", err)
+			log.Printf("failed to apply dashboard: %v", err)
 		}
 	}
 
-	return client.Flush()
+	return nil
 }
 
 func main() {
+	// Run as a long-running process serving Prometheus when an address
+	// is given, otherwise as a Lambda.
+	if addr := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_PROMETHEUS_ADDR"); addr != "" {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		err := Serve(ctx, addr)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	lambda.Start(Start)
 }
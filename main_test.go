@@ -0,0 +1,1338 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	cftypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/alarms"
+	cloudfrontclient "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/cloudfront"
+	cloudwatchclient "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/cloudwatch"
+	organizationsclient "github.com/skpr/cloudfront-invalidation-metrics/internal/aws/organizations"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/clock"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/dashboard"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/discovery"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/metrics"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/metrics/prometheus"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/paths"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/sources"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/state"
+)
+
+func TestExecutePagination(t *testing.T) {
+	now := time.Now()
+
+	cf := cloudfrontclient.MockClient{
+		DistributionPages: [][]cftypes.DistributionSummary{
+			{{Id: aws.String("dist-a")}},
+			{{Id: aws.String("dist-b")}},
+		},
+		InvalidationPages: map[string][][]cftypes.InvalidationSummary{
+			"dist-b": {
+				{
+					{Id: aws.String("inv-1"), CreateTime: aws.Time(now)},
+					{Id: aws.String("inv-2"), CreateTime: aws.Time(now)},
+				},
+				{
+					{Id: aws.String("inv-3"), CreateTime: aws.Time(now)},
+					// Outside of the window, should stop pagination.
+					{Id: aws.String("inv-4"), CreateTime: aws.Time(now.Add(-time.Hour))},
+				},
+				{
+					{Id: aws.String("inv-5"), CreateTime: aws.Time(now)},
+				},
+			},
+		},
+	}
+
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	err = Execute(context.TODO(), cf, client, now.Add(time.Minute), Options{})
+	assert.NoError(t, err)
+
+	values := datumValues(cw.MetricData)
+	assert.Equal(t, float64(1), values["dist-a/InvalidationRequest"])
+	assert.Equal(t, float64(3), values["dist-a/InvalidationPathCounter"])
+	assert.Equal(t, float64(3), values["dist-b/InvalidationRequest"])
+	assert.Equal(t, float64(9), values["dist-b/InvalidationPathCounter"])
+}
+
+func TestExecuteWindow(t *testing.T) {
+	scheduled := time.Date(2024, time.March, 1, 0, 5, 0, 0, time.UTC)
+
+	cf := cloudfrontclient.MockClient{
+		InvalidationPages: map[string][][]cftypes.InvalidationSummary{
+			"test-distribution-id": {
+				{
+					// Created after the window, left for the next run.
+					{Id: aws.String("inv-4"), CreateTime: aws.Time(scheduled.Add(time.Minute))},
+					{Id: aws.String("inv-3"), CreateTime: aws.Time(scheduled.Add(-time.Second))},
+					// The start of the window is inclusive.
+					{Id: aws.String("inv-2"), CreateTime: aws.Time(scheduled.Add(-5 * time.Minute))},
+					{Id: aws.String("inv-1"), CreateTime: aws.Time(scheduled.Add(-6 * time.Minute))},
+				},
+			},
+		},
+	}
+
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	err = Execute(context.TODO(), cf, client, scheduled, Options{
+		Period: 5 * time.Minute,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, float64(2), datumValues(cw.MetricData)["test-distribution-id/InvalidationRequest"])
+
+	// Every datum is timestamped with the end of the window.
+	for _, datum := range cw.MetricData {
+		assert.Equal(t, scheduled, aws.ToTime(datum.Timestamp))
+	}
+}
+
+func TestExecuteClock(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 0, 5, 0, 0, time.UTC)
+
+	cf := cloudfrontclient.MockClient{
+		InvalidationPages: map[string][][]cftypes.InvalidationSummary{
+			"test-distribution-id": {
+				{
+					{Id: aws.String("inv-3"), CreateTime: aws.Time(now)},
+					{Id: aws.String("inv-2"), CreateTime: aws.Time(now.Add(-time.Nanosecond))},
+					{Id: aws.String("inv-1"), CreateTime: aws.Time(now.Add(-time.Minute - time.Nanosecond))},
+				},
+			},
+		},
+	}
+
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	// Without a scheduled time the window ends at the frozen clock.
+	err = Execute(context.TODO(), cf, client, time.Time{}, Options{
+		Clock:  clock.Mock{Time: now},
+		Period: time.Minute,
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, float64(1), datumValues(cw.MetricData)["test-distribution-id/InvalidationRequest"])
+
+	assert.NotEmpty(t, cw.MetricData)
+
+	for _, datum := range cw.MetricData {
+		assert.Equal(t, now, aws.ToTime(datum.Timestamp))
+	}
+}
+
+func TestExecuteStatus(t *testing.T) {
+	scheduled := time.Date(2024, time.March, 1, 0, 5, 0, 0, time.UTC)
+
+	store, err := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+	assert.NoError(t, err)
+
+	var completion []float64
+
+	run := func(scheduled time.Time, invalidations []cftypes.InvalidationSummary) map[string]float64 {
+		cf := cloudfrontclient.MockClient{
+			InvalidationPages: map[string][][]cftypes.InvalidationSummary{
+				"test-distribution-id": {invalidations},
+			},
+		}
+
+		cw := &cloudwatchclient.MockClient{}
+
+		client, err := metrics.New(cw, "dev/null", false)
+		assert.NoError(t, err)
+
+		err = Execute(context.TODO(), cf, client, scheduled, Options{
+			Store: store,
+		})
+		assert.NoError(t, err)
+
+		completion = nil
+
+		for _, datum := range cw.MetricData {
+			if aws.ToString(datum.MetricName) == MetricInvalidationCompletionSeconds {
+				completion = datum.Values
+			}
+		}
+
+		return datumValues(cw.MetricData)
+	}
+
+	values := run(scheduled, []cftypes.InvalidationSummary{
+		{Id: aws.String("inv-3"), Status: aws.String(StatusInProgress), CreateTime: aws.Time(scheduled.Add(-time.Minute))},
+		{Id: aws.String("inv-2"), Status: aws.String(StatusCompleted), CreateTime: aws.Time(scheduled.Add(-2 * time.Minute))},
+		// Created before the window but still within the lookback.
+		{Id: aws.String("inv-1"), Status: aws.String(StatusInProgress), CreateTime: aws.Time(scheduled.Add(-30 * time.Minute))},
+	})
+	assert.Equal(t, float64(2), values["test-distribution-id/InvalidationsInProgress"])
+	assert.Equal(t, float64(1), values["test-distribution-id/InvalidationsCompleted"])
+	assert.Equal(t, []float64{120}, completion)
+
+	scheduled = scheduled.Add(5 * time.Minute)
+
+	values = run(scheduled, []cftypes.InvalidationSummary{
+		{Id: aws.String("inv-4"), Status: aws.String(StatusInProgress), CreateTime: aws.Time(scheduled.Add(-time.Minute))},
+		{Id: aws.String("inv-3"), Status: aws.String(StatusCompleted), CreateTime: aws.Time(scheduled.Add(-6 * time.Minute))},
+		{Id: aws.String("inv-2"), Status: aws.String(StatusCompleted), CreateTime: aws.Time(scheduled.Add(-7 * time.Minute))},
+		{Id: aws.String("inv-1"), Status: aws.String(StatusCompleted), CreateTime: aws.Time(scheduled.Add(-35 * time.Minute))},
+	})
+	assert.Equal(t, float64(1), values["test-distribution-id/InvalidationsInProgress"])
+	assert.Equal(t, float64(2), values["test-distribution-id/InvalidationsCompleted"])
+	assert.Equal(t, []float64{360, 2100}, completion)
+}
+
+func TestExecuteWildcards(t *testing.T) {
+	now := time.Now()
+
+	cf := cloudfrontclient.MockClient{
+		InvalidationPages: map[string][][]cftypes.InvalidationSummary{
+			"test-distribution-id": {
+				{
+					{Id: aws.String("inv-2"), CreateTime: aws.Time(now)},
+					{Id: aws.String("inv-1"), CreateTime: aws.Time(now)},
+				},
+			},
+		},
+		Invalidations: map[string]cftypes.Invalidation{
+			"inv-2": newInvalidation("inv-2", "/*", "/index.html"),
+			"inv-1": newInvalidation("inv-1", "/images/*", "/css/*", "/index.html"),
+		},
+	}
+
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	err = Execute(context.TODO(), cf, client, now.Add(time.Minute), Options{})
+	assert.NoError(t, err)
+
+	values := datumValues(cw.MetricData)
+	assert.Equal(t, float64(5), values["test-distribution-id/InvalidationPathCounter"])
+	assert.Equal(t, float64(3), values["test-distribution-id/InvalidationWildcardPaths"])
+	assert.Equal(t, float64(1), values["test-distribution-id/InvalidationFullPurge"])
+}
+
+func TestExecutePathGroups(t *testing.T) {
+	now := time.Now()
+
+	cf := cloudfrontclient.MockClient{
+		InvalidationPages: map[string][][]cftypes.InvalidationSummary{
+			"test-distribution-id": {
+				{
+					{Id: aws.String("inv-1"), CreateTime: aws.Time(now)},
+				},
+			},
+		},
+		Invalidations: map[string]cftypes.Invalidation{
+			"inv-1": newInvalidation("inv-1", "/api/users", "/api/posts/*", "/static/app.js", "/index.html"),
+		},
+	}
+
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	err = Execute(context.TODO(), cf, client, now.Add(time.Minute), Options{
+		PathGroups: paths.Groups{
+			{Name: "API", Prefix: "/api/"},
+			{Name: "Static", Prefix: "/static/"},
+			{Name: "Media", Prefix: "/media/"},
+		},
+	})
+	assert.NoError(t, err)
+
+	groups := make(map[string]float64)
+
+	for _, datum := range cw.MetricData {
+		for _, dimension := range datum.Dimensions {
+			if aws.ToString(dimension.Name) == "PathGroup" {
+				assert.Equal(t, MetricInvalidationPathCounter, aws.ToString(datum.MetricName))
+				groups[aws.ToString(dimension.Value)] = aws.ToFloat64(datum.Value)
+			}
+		}
+	}
+
+	assert.Equal(t, map[string]float64{
+		"API":    2,
+		"Static": 1,
+		"Media":  0,
+		"Other":  1,
+	}, groups)
+
+	// The total across every group is still published without the dimension.
+	assert.Equal(t, float64(4), datumValues(cw.MetricData)["test-distribution-id/InvalidationPathCounter"])
+}
+
+func TestExecuteSources(t *testing.T) {
+	now := time.Now()
+
+	invalidations := map[string]cftypes.Invalidation{
+		"inv-3": newInvalidation("inv-3", "/*"),
+		"inv-2": newInvalidation("inv-2", "/node/1", "/node/2"),
+		"inv-1": newInvalidation("inv-1", "/node/3"),
+	}
+
+	invalidations["inv-3"].InvalidationBatch.CallerReference = aws.String("ci-github-42")
+	invalidations["inv-2"].InvalidationBatch.CallerReference = aws.String("drupal-1700000000")
+	invalidations["inv-1"].InvalidationBatch.CallerReference = aws.String("drupal-1700000001")
+
+	cf := cloudfrontclient.MockClient{
+		InvalidationPages: map[string][][]cftypes.InvalidationSummary{
+			"test-distribution-id": {
+				{
+					{Id: aws.String("inv-3"), CreateTime: aws.Time(now)},
+					{Id: aws.String("inv-2"), CreateTime: aws.Time(now)},
+					{Id: aws.String("inv-1"), CreateTime: aws.Time(now)},
+				},
+			},
+		},
+		Invalidations: invalidations,
+	}
+
+	rules, err := sources.ParseRules(`[{"name": "CMS", "pattern": "^drupal-"}, {"pattern": "^ci-(?P<source>[a-z]+)-"}]`)
+	assert.NoError(t, err)
+
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	err = Execute(context.TODO(), cf, client, now.Add(time.Minute), Options{
+		Sources: rules,
+	})
+	assert.NoError(t, err)
+
+	values := make(map[string]float64)
+
+	for _, datum := range cw.MetricData {
+		for _, dimension := range datum.Dimensions {
+			if aws.ToString(dimension.Name) == "Source" {
+				values[aws.ToString(dimension.Value)+"/"+aws.ToString(datum.MetricName)] = aws.ToFloat64(datum.Value)
+			}
+		}
+	}
+
+	assert.Equal(t, map[string]float64{
+		"CMS/InvalidationRequest":        2,
+		"CMS/InvalidationPathCounter":    3,
+		"github/InvalidationRequest":     1,
+		"github/InvalidationPathCounter": 1,
+	}, values)
+}
+
+func TestExecuteTagDimensions(t *testing.T) {
+	cf := cloudfrontclient.MockClient{
+		DistributionPages: [][]cftypes.DistributionSummary{
+			{
+				{Id: aws.String("dist-a"), ARN: aws.String("arn:dist-a")},
+				{Id: aws.String("dist-b"), ARN: aws.String("arn:dist-b")},
+			},
+		},
+		Tags: map[string][]cftypes.Tag{
+			"arn:dist-a": {
+				{Key: aws.String("Owner"), Value: aws.String("ops")},
+				{Key: aws.String("Environment"), Value: aws.String("prod")},
+				{Key: aws.String("Project"), Value: aws.String("website")},
+			},
+			"arn:dist-b": {
+				{Key: aws.String("Project"), Value: aws.String("intranet")},
+			},
+		},
+	}
+
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	err = Execute(context.TODO(), cf, client, time.Time{}, Options{
+		TagDimensions: []string{"Project", "Environment"},
+	})
+	assert.NoError(t, err)
+
+	dimensions := make(map[string][]string)
+
+	for _, datum := range cw.MetricData {
+		var names []string
+
+		for _, dimension := range datum.Dimensions {
+			names = append(names, aws.ToString(dimension.Name)+"="+aws.ToString(dimension.Value))
+		}
+
+		dimensions[names[0]] = names
+	}
+
+	// Tags are added in the configured order, skipping those which are missing.
+	assert.Equal(t, map[string][]string{
+		"Distribution=dist-a": {"Distribution=dist-a", "Project=website", "Environment=prod"},
+		"Distribution=dist-b": {"Distribution=dist-b", "Project=intranet"},
+	}, dimensions)
+}
+
+func TestExecuteNameDimension(t *testing.T) {
+	cf := cloudfrontclient.MockClient{
+		DistributionPages: [][]cftypes.DistributionSummary{
+			{
+				{
+					Id:      aws.String("dist-a"),
+					Comment: aws.String("Website"),
+					Aliases: &cftypes.Aliases{
+						Items: []string{"www.example.com", "*.example.com", "example.com"},
+					},
+				},
+				{
+					Id:      aws.String("dist-b"),
+					Comment: aws.String(""),
+				},
+			},
+		},
+	}
+
+	for mode, expected := range map[string][]types.Dimension{
+		NameDimensionAlias: {
+			{Name: aws.String("PrimaryAlias"), Value: aws.String("example.com")},
+		},
+		NameDimensionComment: {
+			{Name: aws.String("Name"), Value: aws.String("Website")},
+		},
+	} {
+		cw := &cloudwatchclient.MockClient{}
+
+		client, err := metrics.New(cw, "dev/null", false)
+		assert.NoError(t, err)
+
+		err = Execute(context.TODO(), cf, client, time.Time{}, Options{
+			NameDimension: mode,
+		})
+		assert.NoError(t, err)
+
+		for _, datum := range cw.MetricData {
+			switch aws.ToString(datum.Dimensions[0].Value) {
+			case "dist-a":
+				assert.Equal(t, expected, datum.Dimensions[1:])
+			case "dist-b":
+				// Nothing to name the distribution by.
+				assert.Len(t, datum.Dimensions, 1)
+			}
+		}
+	}
+}
+
+func TestPrimaryAlias(t *testing.T) {
+	assert.Equal(t, "", primaryAlias(nil))
+	assert.Equal(t, "www.example.com", primaryAlias([]string{"*.example.com", "www.example.com"}))
+	assert.Equal(t, "cdn.example.com", primaryAlias([]string{"www.example.com", "cdn.example.com"}))
+	assert.Equal(t, "example.com", primaryAlias([]string{"static.example.com", "example.com"}))
+	assert.Equal(t, "*.example.com", primaryAlias([]string{"*.example.com"}))
+}
+
+func TestExecuteBilling(t *testing.T) {
+	scheduled := time.Date(2024, time.March, 31, 23, 50, 0, 0, time.UTC)
+
+	store, err := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+	assert.NoError(t, err)
+
+	run := func(scheduled time.Time, paths map[string]int) map[string]float64 {
+		cf := cloudfrontclient.MockClient{
+			DistributionPages: [][]cftypes.DistributionSummary{
+				{{Id: aws.String("dist-a")}, {Id: aws.String("dist-b")}},
+			},
+			InvalidationPages: make(map[string][][]cftypes.InvalidationSummary),
+			Invalidations:     make(map[string]cftypes.Invalidation),
+		}
+
+		for distribution, quantity := range paths {
+			id := distribution + scheduled.String()
+
+			cf.InvalidationPages[distribution] = [][]cftypes.InvalidationSummary{
+				{{Id: aws.String(id), CreateTime: aws.Time(scheduled.Add(-time.Minute))}},
+			}
+
+			cf.Invalidations[id] = newInvalidation(id, make([]string, quantity)...)
+		}
+
+		cw := &cloudwatchclient.MockClient{}
+
+		client, err := metrics.New(cw, "dev/null", false)
+		assert.NoError(t, err)
+
+		err = Execute(context.TODO(), cf, client, scheduled, Options{
+			Store: store,
+		})
+		assert.NoError(t, err)
+
+		return datumValues(cw.MetricData)
+	}
+
+	values := run(scheduled, map[string]int{"dist-a": 600, "dist-b": 300})
+	assert.Equal(t, float64(900), values["/InvalidationPathsMonthToDate"])
+	assert.Equal(t, float64(100), values["/InvalidationFreeTierRemaining"])
+	assert.Equal(t, float64(0), values["/InvalidationEstimatedCostUSD"])
+	assert.Equal(t, float64(600), values["dist-a/InvalidationPathsMonthToDate"])
+
+	// The free tier is used up, with the cost shared by paths invalidated.
+	values = run(scheduled.Add(5*time.Minute), map[string]int{"dist-a": 200})
+	assert.Equal(t, float64(1100), values["/InvalidationPathsMonthToDate"])
+	assert.Equal(t, float64(0), values["/InvalidationFreeTierRemaining"])
+	assert.InDelta(t, 0.5, values["/InvalidationEstimatedCostUSD"], 0.000001)
+	assert.InDelta(t, 0.5*800/1100, values["dist-a/InvalidationEstimatedCostUSD"], 0.000001)
+	assert.InDelta(t, 0.5*300/1100, values["dist-b/InvalidationEstimatedCostUSD"], 0.000001)
+
+	// A new month starts from scratch.
+	values = run(scheduled.Add(15*time.Minute), map[string]int{"dist-b": 10})
+	assert.Equal(t, float64(10), values["/InvalidationPathsMonthToDate"])
+	assert.Equal(t, float64(0), values["dist-a/InvalidationPathsMonthToDate"])
+	assert.Equal(t, float64(10), values["dist-b/InvalidationPathsMonthToDate"])
+}
+
+func TestExecuteBillingRollover(t *testing.T) {
+	scheduled := time.Date(2024, time.April, 1, 0, 5, 0, 0, time.UTC)
+
+	store, err := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+	assert.NoError(t, err)
+
+	// The last run of March counted up to the checkpoint.
+	assert.NoError(t, store.Put(context.TODO(), "test-distribution-id", state.State{
+		Checkpoint: state.Checkpoint{
+			InvalidationID:  "inv-1",
+			CreateTime:      time.Date(2024, time.March, 31, 23, 49, 0, 0, time.UTC),
+			InvalidationIDs: []string{"inv-1"},
+		},
+		Month:      "2024-03",
+		MonthPaths: 600,
+	}))
+
+	cf := cloudfrontclient.MockClient{
+		InvalidationPages: map[string][][]cftypes.InvalidationSummary{
+			"test-distribution-id": {{
+				{Id: aws.String("inv-3"), CreateTime: aws.Time(scheduled.Add(-time.Minute))},
+				{Id: aws.String("inv-2"), CreateTime: aws.Time(time.Date(2024, time.March, 31, 23, 58, 0, 0, time.UTC))},
+				{Id: aws.String("inv-1"), CreateTime: aws.Time(time.Date(2024, time.March, 31, 23, 49, 0, 0, time.UTC))},
+			}},
+		},
+		Invalidations: map[string]cftypes.Invalidation{
+			"inv-3": newInvalidation("inv-3", make([]string, 10)...),
+			"inv-2": newInvalidation("inv-2", make([]string, 50)...),
+		},
+	}
+
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	err = Execute(context.TODO(), cf, client, scheduled, Options{
+		Store: store,
+	})
+	assert.NoError(t, err)
+
+	values := datumValues(cw.MetricData)
+
+	// Both are counted by the first run of April.
+	assert.Equal(t, float64(60), values["test-distribution-id/InvalidationPathCounter"])
+
+	// The paths invalidated in March are left out of April, and March's
+	// total is no longer published, so they are lost from both.
+	assert.Equal(t, float64(10), values["test-distribution-id/InvalidationPathsMonthToDate"])
+
+	current, err := store.Get(context.TODO(), "test-distribution-id")
+	assert.NoError(t, err)
+	assert.Equal(t, "2024-04", current.Month)
+	assert.Equal(t, float64(10), current.MonthPaths)
+}
+
+func TestExecuteQuotas(t *testing.T) {
+	now := time.Now()
+
+	cf := cloudfrontclient.MockClient{
+		InvalidationPages: map[string][][]cftypes.InvalidationSummary{
+			"test-distribution-id": {
+				{
+					{Id: aws.String("inv-3"), Status: aws.String(StatusInProgress), CreateTime: aws.Time(now)},
+					{Id: aws.String("inv-2"), Status: aws.String(StatusCompleted), CreateTime: aws.Time(now)},
+					// In progress from before the window still counts towards the quotas.
+					{Id: aws.String("inv-1"), Status: aws.String(StatusInProgress), CreateTime: aws.Time(now.Add(-30 * time.Minute))},
+				},
+			},
+		},
+		Invalidations: map[string]cftypes.Invalidation{
+			"inv-3": newInvalidation("inv-3", "/*", "/index.html"),
+			"inv-2": newInvalidation("inv-2", "/images/*", "/about.html"),
+			"inv-1": newInvalidation("inv-1", "/css/*", "/a.html", "/b.html", "/c.html"),
+		},
+	}
+
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	err = Execute(context.TODO(), cf, client, now.Add(time.Minute), Options{
+		PathQuota:     8,
+		WildcardQuota: 4,
+	})
+	assert.NoError(t, err)
+
+	values := datumValues(cw.MetricData)
+	assert.Equal(t, float64(4), values["test-distribution-id/InvalidationPathsInProgress"])
+	assert.Equal(t, float64(2), values["test-distribution-id/InvalidationWildcardPathsInProgress"])
+	assert.Equal(t, float64(50), values["test-distribution-id/InvalidationPathQuotaUtilisation"])
+	assert.Equal(t, float64(50), values["test-distribution-id/InvalidationWildcardQuotaUtilisation"])
+}
+
+func TestExecuteAlarms(t *testing.T) {
+	cf := cloudfrontclient.MockClient{
+		DistributionPages: [][]cftypes.DistributionSummary{
+			{
+				{
+					Id:      aws.String("dist-a"),
+					Comment: aws.String("Website"),
+				},
+			},
+		},
+	}
+
+	cw := &cloudwatchclient.MockClient{
+		Alarms: map[string]types.MetricAlarm{
+			// Distribution which no longer exists.
+			"CloudFrontInvalidation-dist-b-Paths": {AlarmName: aws.String("CloudFrontInvalidation-dist-b-Paths")},
+		},
+	}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	templates, err := alarms.ParseTemplates(`[{"name": "Paths", "metric": "InvalidationPathCounter", "threshold": 100}]`)
+	assert.NoError(t, err)
+
+	reconciler, err := alarms.New(cw, CloudWatchNamespace, alarms.DefaultPrefix, templates)
+	assert.NoError(t, err)
+
+	err = Execute(context.TODO(), cf, client, time.Now(), Options{
+		NameDimension: NameDimensionComment,
+		Alarms:        reconciler,
+	})
+	assert.NoError(t, err)
+
+	assert.Len(t, cw.Alarms, 1)
+
+	// Alarms have the same dimensions as the published metrics.
+	alarm := cw.Alarms["CloudFrontInvalidation-dist-a-Paths"]
+	assert.Equal(t, []types.Dimension{
+		{Name: aws.String("Distribution"), Value: aws.String("dist-a")},
+		{Name: aws.String("Name"), Value: aws.String("Website")},
+	}, alarm.Dimensions)
+}
+
+func TestExecuteDashboard(t *testing.T) {
+	cf := cloudfrontclient.MockClient{
+		DistributionPages: [][]cftypes.DistributionSummary{
+			{
+				{Id: aws.String("dist-a")},
+				{Id: aws.String("dist-b")},
+			},
+		},
+	}
+
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	err = Execute(context.TODO(), cf, client, time.Now(), Options{
+		Dashboard: &dashboard.Dashboard{
+			CloudWatch: cw,
+			Name:       "CloudFrontInvalidations",
+			Namespace:  CloudWatchNamespace,
+			Graphs:     distributionGraphs,
+		},
+	})
+	assert.NoError(t, err)
+
+	var body dashboard.Body
+
+	err = json.Unmarshal([]byte(cw.Dashboards["CloudFrontInvalidations"]), &body)
+	assert.NoError(t, err)
+
+	// A heading and graphs for each distribution.
+	assert.Len(t, body.Widgets, 2*(1+len(distributionGraphs)))
+
+	// Every graphed metric is one which is published.
+	published := make(map[string]bool)
+
+	for _, datum := range cw.MetricData {
+		published[aws.ToString(datum.MetricName)] = true
+	}
+
+	for _, graph := range distributionGraphs {
+		for _, metric := range graph.Metrics {
+			assert.True(t, published[metric], metric)
+		}
+	}
+
+	// Completions are not published without state, as they would be low.
+	assert.False(t, published[MetricInvalidationsCompleted])
+	assert.False(t, published[MetricInvalidationCompletionSeconds])
+}
+
+func TestExecuteDashboardFailure(t *testing.T) {
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	// A row of graphs too large for any dashboard cannot be rendered.
+	err = Execute(context.TODO(), cloudfrontclient.MockClient{}, client, time.Now(), Options{
+		Dashboard: &dashboard.Dashboard{
+			CloudWatch: cw,
+			Name:       "CloudFrontInvalidations",
+			Graphs:     make([]dashboard.Graph, dashboard.MaxWidgets),
+		},
+	})
+
+	// The metrics were pushed, so the run still succeeds.
+	assert.NoError(t, err)
+	assert.NotEmpty(t, cw.MetricData)
+	assert.Empty(t, cw.DashboardPuts)
+}
+
+func TestCollectEvery(t *testing.T) {
+	period := 5 * time.Minute
+
+	// Part way through the current period so the next run is in the future.
+	aligned := time.Now().Truncate(period)
+	now := aligned.Add(time.Minute)
+
+	cf := cloudfrontclient.MockClient{
+		InvalidationPages: map[string][][]cftypes.InvalidationSummary{
+			"test-distribution-id": {
+				{
+					{Id: aws.String("inv-1"), Status: aws.String(StatusCompleted), CreateTime: aws.Time(aligned.Add(-270 * time.Second))},
+				},
+			},
+		},
+		Invalidations: map[string]cftypes.Invalidation{
+			"inv-1": newInvalidation("inv-1", "/*"),
+		},
+	}
+
+	registry, err := prometheus.New(PrometheusPrefix, prometheusTypes())
+	assert.NoError(t, err)
+
+	// Stops after the first run when the server is closed.
+	errs := make(chan error, 1)
+	errs <- http.ErrServerClosed
+
+	err = collectEvery(context.TODO(), []Account{{CloudFront: cf}}, nil, registry, Options{
+		Clock:  clock.Mock{Time: now},
+		Period: period,
+	}, errs)
+	assert.NoError(t, err)
+
+	var body strings.Builder
+
+	_, err = registry.WriteTo(&body)
+	assert.NoError(t, err)
+
+	// The window is aligned to the period, so it includes the invalidation
+	// created four and a half minutes before the start of this period.
+	assert.Contains(t, body.String(), `cloudfront_invalidation_request_total{distribution="test-distribution-id"} 1`)
+	assert.Contains(t, body.String(), `cloudfront_invalidation_full_purge_total{distribution="test-distribution-id"} 1`)
+
+	// Each account flushes in turn, without replacing the gauges of the
+	// accounts flushed before it.
+	registry, err = prometheus.New(PrometheusPrefix, prometheusTypes())
+	assert.NoError(t, err)
+
+	errs <- http.ErrServerClosed
+
+	err = collectEvery(context.TODO(), []Account{
+		{ID: "111111111111", CloudFront: cf},
+		{ID: "222222222222", CloudFront: cf},
+	}, nil, registry, Options{
+		Clock:  clock.Mock{Time: now},
+		Period: period,
+	}, errs)
+	assert.NoError(t, err)
+
+	body.Reset()
+
+	_, err = registry.WriteTo(&body)
+	assert.NoError(t, err)
+
+	assert.Contains(t, body.String(), `cloudfront_invalidations_in_progress{account_id="111111111111",distribution="test-distribution-id"} 0`)
+	assert.Contains(t, body.String(), `cloudfront_invalidations_in_progress{account_id="222222222222",distribution="test-distribution-id"} 0`)
+}
+
+func TestCollectEveryRefresh(t *testing.T) {
+	registry, err := prometheus.New(PrometheusPrefix, prometheusTypes())
+	assert.NoError(t, err)
+
+	errs := make(chan error, 1)
+
+	var refreshes int
+
+	// The new account is discovered before the second period, after which
+	// the server closes.
+	refresh := func(ctx context.Context) ([]Account, error) {
+		refreshes++
+		errs <- http.ErrServerClosed
+
+		return []Account{
+			{ID: "111111111111", CloudFront: cloudfrontclient.MockClient{}},
+			{ID: "222222222222", CloudFront: cloudfrontclient.MockClient{}},
+		}, nil
+	}
+
+	err = collectEvery(context.TODO(), []Account{
+		{ID: "111111111111", CloudFront: cloudfrontclient.MockClient{}},
+	}, refresh, registry, Options{
+		Clock:  clock.Real{},
+		Period: 10 * time.Millisecond,
+	}, errs)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, refreshes)
+
+	var body strings.Builder
+
+	_, err = registry.WriteTo(&body)
+	assert.NoError(t, err)
+
+	assert.Contains(t, body.String(), `cloudfront_invalidations_in_progress{account_id="111111111111",distribution="test-distribution-id"} 0`)
+	assert.Contains(t, body.String(), `cloudfront_invalidations_in_progress{account_id="222222222222",distribution="test-distribution-id"} 0`)
+}
+
+func TestExecuteConcurrency(t *testing.T) {
+	var distributions []cftypes.DistributionSummary
+
+	for i := 0; i < 50; i++ {
+		id := fmt.Sprintf("dist-%02d", i)
+		distributions = append(distributions, cftypes.DistributionSummary{Id: aws.String(id)})
+	}
+
+	cf := cloudfrontclient.MockClient{
+		DistributionPages: [][]cftypes.DistributionSummary{distributions},
+	}
+
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	err = Execute(context.TODO(), cf, client, time.Time{}, Options{
+		Concurrency: 8,
+	})
+	assert.NoError(t, err)
+
+	// Metrics are pushed in distribution order regardless of which worker
+	// finished first.
+	var actual []string
+
+	for _, datum := range cw.MetricData {
+		actual = append(actual, aws.ToString(datum.Dimensions[0].Value))
+	}
+
+	assert.Len(t, actual, len(distributions)*len(distributionResult{}.datums(time.Time{})))
+	assert.IsNonDecreasing(t, actual)
+}
+
+func TestExecuteCheckpoint(t *testing.T) {
+	now := time.Now()
+
+	store, err := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+	assert.NoError(t, err)
+
+	older := []cftypes.InvalidationSummary{
+		{Id: aws.String("inv-2"), CreateTime: aws.Time(now.Add(-time.Minute))},
+		{Id: aws.String("inv-1"), CreateTime: aws.Time(now.Add(-2 * time.Minute))},
+	}
+
+	run := func(invalidations []cftypes.InvalidationSummary) map[string]float64 {
+		cf := cloudfrontclient.MockClient{
+			InvalidationPages: map[string][][]cftypes.InvalidationSummary{
+				"test-distribution-id": {invalidations},
+			},
+		}
+
+		cw := &cloudwatchclient.MockClient{}
+
+		client, err := metrics.New(cw, "dev/null", false)
+		assert.NoError(t, err)
+
+		err = Execute(context.TODO(), cf, client, now.Add(time.Minute), Options{
+			Store: store,
+		})
+		assert.NoError(t, err)
+
+		return datumValues(cw.MetricData)
+	}
+
+	// The first run has no checkpoint so falls back to the window.
+	assert.Equal(t, float64(2), run(older)["test-distribution-id/InvalidationRequest"])
+
+	// Nothing new has been created since the previous run.
+	assert.Equal(t, float64(0), run(older)["test-distribution-id/InvalidationRequest"])
+
+	// Only the invalidation created since the previous run is counted.
+	newer := append([]cftypes.InvalidationSummary{
+		{Id: aws.String("inv-3"), CreateTime: aws.Time(now)},
+	}, older...)
+	assert.Equal(t, float64(1), run(newer)["test-distribution-id/InvalidationRequest"])
+
+	current, err := store.Get(context.TODO(), "test-distribution-id")
+	assert.NoError(t, err)
+	assert.Equal(t, "inv-3", current.Checkpoint.InvalidationID)
+
+	// Create times are only precise to the second, so one created at the
+	// same time as the checkpoint is counted even when listed after it.
+	shared := []cftypes.InvalidationSummary{
+		newer[0],
+		{Id: aws.String("inv-4"), CreateTime: aws.Time(now)},
+		older[0],
+		older[1],
+	}
+	assert.Equal(t, float64(1), run(shared)["test-distribution-id/InvalidationRequest"])
+
+	// Neither is counted again, whichever order they are listed in.
+	shared[0], shared[1] = shared[1], shared[0]
+	assert.Equal(t, float64(0), run(shared)["test-distribution-id/InvalidationRequest"])
+
+	shared[0], shared[1] = shared[1], shared[0]
+	assert.Equal(t, float64(0), run(shared)["test-distribution-id/InvalidationRequest"])
+}
+
+func TestExecuteDryRun(t *testing.T) {
+	now := time.Now()
+
+	store, err := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+	assert.NoError(t, err)
+
+	cf := cloudfrontclient.MockClient{
+		InvalidationPages: map[string][][]cftypes.InvalidationSummary{
+			"test-distribution-id": {
+				{{Id: aws.String("inv-1"), CreateTime: aws.Time(now.Add(-time.Minute))}},
+			},
+		},
+	}
+
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", true)
+	assert.NoError(t, err)
+
+	client.Output = io.Discard
+
+	templates, err := alarms.ParseTemplates(`[{"name": "Paths", "metric": "InvalidationPathCounter", "threshold": 100}]`)
+	assert.NoError(t, err)
+
+	reconciler, err := alarms.New(cw, CloudWatchNamespace, alarms.DefaultPrefix, templates)
+	assert.NoError(t, err)
+
+	err = Execute(context.TODO(), cf, client, now, Options{
+		DryRun: true,
+		Store:  store,
+		Alarms: reconciler,
+		Dashboard: &dashboard.Dashboard{
+			CloudWatch: cw,
+			Name:       "CloudFrontInvalidations",
+			Namespace:  CloudWatchNamespace,
+			Graphs:     distributionGraphs,
+		},
+	})
+	assert.NoError(t, err)
+
+	// Checkpoints are left for the next real run.
+	current, err := store.Get(context.TODO(), "test-distribution-id")
+	assert.NoError(t, err)
+	assert.Empty(t, current.Checkpoint.InvalidationID)
+
+	account, err := store.Get(context.TODO(), AccountStateKey)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(0), account.MonthPaths)
+
+	// Alarms and dashboards are not managed.
+	assert.Empty(t, cw.Alarms)
+	assert.Empty(t, cw.DashboardPuts)
+}
+
+func TestExecuteAccounts(t *testing.T) {
+	now := time.Now()
+
+	account := func(distribution string) cloudfrontclient.MockClient {
+		return cloudfrontclient.MockClient{
+			DistributionPages: [][]cftypes.DistributionSummary{
+				{{Id: aws.String(distribution)}},
+			},
+			InvalidationPages: map[string][][]cftypes.InvalidationSummary{
+				distribution: {
+					{{Id: aws.String(distribution + "-inv"), CreateTime: aws.Time(now.Add(-time.Minute))}},
+				},
+			},
+			Invalidations: map[string]cftypes.Invalidation{
+				distribution + "-inv": newInvalidation(distribution+"-inv", "/a.html", "/b.html"),
+			},
+		}
+	}
+
+	store, err := state.NewFileStore(filepath.Join(t.TempDir(), "state.json"))
+	assert.NoError(t, err)
+
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	templates, err := alarms.ParseTemplates(`[{"name": "Paths", "metric": "InvalidationPathCounter", "threshold": 100}]`)
+	assert.NoError(t, err)
+
+	reconciler, err := alarms.New(cw, CloudWatchNamespace, alarms.DefaultPrefix, templates)
+	assert.NoError(t, err)
+
+	err = ExecuteAccounts(context.TODO(), []Account{
+		{ID: "111111111111", CloudFront: account("dist-a")},
+		{ID: "222222222222", CloudFront: account("dist-b")},
+	}, client, now, Options{
+		Store:  store,
+		Alarms: reconciler,
+	})
+	assert.NoError(t, err)
+
+	values := make(map[string]float64)
+
+	for _, datum := range cw.MetricData {
+		var key []string
+
+		for _, dimension := range datum.Dimensions {
+			key = append(key, aws.ToString(dimension.Value))
+		}
+
+		values[strings.Join(append(key, aws.ToString(datum.MetricName)), "/")] = aws.ToFloat64(datum.Value)
+	}
+
+	// Every metric is identified by the account it was collected from.
+	assert.Equal(t, float64(2), values["dist-a/111111111111/InvalidationPathCounter"])
+	assert.Equal(t, float64(2), values["dist-b/222222222222/InvalidationPathCounter"])
+	assert.Equal(t, float64(2), values["111111111111/InvalidationPathsMonthToDate"])
+	assert.Equal(t, float64(2), values["222222222222/InvalidationPathsMonthToDate"])
+
+	// Billing is tracked separately for each account.
+	for _, key := range []string{"account/111111111111", "account/222222222222"} {
+		current, err := store.Get(context.TODO(), key)
+		assert.NoError(t, err)
+		assert.Equal(t, float64(2), current.MonthPaths)
+	}
+
+	// Alarms are reconciled under a prefix for each account, so one account
+	// does not delete the alarms of another.
+	assert.Len(t, cw.Alarms, 2)
+	assert.Contains(t, cw.Alarms, "CloudFrontInvalidation-111111111111-dist-a-Paths")
+	assert.Contains(t, cw.Alarms, "CloudFrontInvalidation-222222222222-dist-b-Paths")
+}
+
+func TestExecuteAccountsDashboard(t *testing.T) {
+	account := func(distribution string) cloudfrontclient.MockClient {
+		return cloudfrontclient.MockClient{
+			DistributionPages: [][]cftypes.DistributionSummary{
+				{{Id: aws.String(distribution)}},
+			},
+		}
+	}
+
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	err = ExecuteAccounts(context.TODO(), []Account{
+		{ID: "111111111111", CloudFront: account("dist-a")},
+		{ID: "222222222222", CloudFront: account("dist-b")},
+	}, client, time.Now(), Options{
+		Dashboard: &dashboard.Dashboard{
+			CloudWatch: cw,
+			Name:       "CloudFrontInvalidations",
+			Namespace:  CloudWatchNamespace,
+			Account:    accountGraphs,
+			Graphs:     distributionGraphs,
+		},
+	})
+	assert.NoError(t, err)
+
+	// Each account has its own dashboard, graphing the account metrics with
+	// the dimension they are published with.
+	for _, id := range []string{"111111111111", "222222222222"} {
+		body := cw.Dashboards["CloudFrontInvalidations-"+id]
+		assert.Contains(t, body, `"## Account"`)
+		assert.Contains(t, body, fmt.Sprintf(`"%s","InvalidationPathsMonthToDate","AccountId","%s"`, CloudWatchNamespace, id))
+	}
+}
+
+func TestExecuteAccountsFailure(t *testing.T) {
+	cw := &cloudwatchclient.MockClient{}
+
+	client, err := metrics.New(cw, "dev/null", false)
+	assert.NoError(t, err)
+
+	err = ExecuteAccounts(context.TODO(), []Account{
+		{ID: "111111111111", CloudFront: deniedClient{}},
+		{ID: "222222222222", CloudFront: cloudfrontclient.MockClient{
+			DistributionPages: [][]cftypes.DistributionSummary{
+				{{Id: aws.String("dist-b")}},
+			},
+		}},
+	}, client, time.Now(), Options{})
+	assert.ErrorContains(t, err, "account 111111111111")
+
+	// The other account is still collected from.
+	assert.NotEmpty(t, cw.MetricData)
+}
+
+func TestAssumeRoles(t *testing.T) {
+	accounts, err := assumeRoles(aws.Config{Region: "ap-southeast-2"}, []string{
+		"arn:aws:iam::111111111111:role/cloudfront-invalidation-metrics",
+		"arn:aws:iam::222222222222:role/cloudfront-invalidation-metrics",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 2)
+	assert.Equal(t, "111111111111", accounts[0].ID)
+	assert.Equal(t, "222222222222", accounts[1].ID)
+
+	_, err = assumeRoles(aws.Config{}, []string{"cloudfront-invalidation-metrics"})
+	assert.ErrorContains(t, err, "failed to parse role arn")
+}
+
+func TestDiscoverAccounts(t *testing.T) {
+	account := func(id string) orgtypes.Account {
+		return orgtypes.Account{
+			Id:     aws.String(id),
+			Status: orgtypes.AccountStatusActive,
+		}
+	}
+
+	discoverer, err := discovery.New(organizationsclient.MockClient{
+		AccountPages: [][]orgtypes.Account{
+			{account("111111111111"), account("222222222222"), account("333333333333")},
+		},
+	}, nil, nil)
+	assert.NoError(t, err)
+
+	client := &assumeRoleClient{
+		Missing: map[string]bool{
+			"arn:aws:iam::222222222222:role/cloudfront-invalidation-metrics": true,
+		},
+	}
+
+	accounts, err := discoverAccounts(context.TODO(), aws.Config{Region: "ap-southeast-2"}, discoverer, client, discovery.DefaultRole)
+	assert.NoError(t, err)
+
+	// The account without the role is skipped.
+	assert.Len(t, accounts, 2)
+	assert.Equal(t, "111111111111", accounts[0].ID)
+	assert.Equal(t, "333333333333", accounts[1].ID)
+
+	assert.Equal(t, []string{
+		"arn:aws:iam::111111111111:role/cloudfront-invalidation-metrics",
+		"arn:aws:iam::222222222222:role/cloudfront-invalidation-metrics",
+		"arn:aws:iam::333333333333:role/cloudfront-invalidation-metrics",
+	}, client.Assumed)
+
+	// When no role can be assumed, the Lambda's own role is likely to blame.
+	client.Missing = map[string]bool{
+		"arn:aws:iam::111111111111:role/cloudfront-invalidation-metrics": true,
+		"arn:aws:iam::222222222222:role/cloudfront-invalidation-metrics": true,
+		"arn:aws:iam::333333333333:role/cloudfront-invalidation-metrics": true,
+	}
+
+	_, err = discoverAccounts(context.TODO(), aws.Config{Region: "ap-southeast-2"}, discoverer, client, discovery.DefaultRole)
+	assert.ErrorContains(t, err, "could not be assumed in any of the 3 discovered accounts")
+
+	// So does discovering no accounts at all.
+	empty, err := discovery.New(organizationsclient.MockClient{}, nil, nil)
+	assert.NoError(t, err)
+
+	_, err = discoverAccounts(context.TODO(), aws.Config{Region: "ap-southeast-2"}, empty, client, discovery.DefaultRole)
+	assert.ErrorContains(t, err, "no accounts were discovered")
+
+	// Other failures, such as throttling, fail discovery.
+	client.Err = &smithy.GenericAPIError{Code: "Throttling"}
+
+	_, err = discoverAccounts(context.TODO(), aws.Config{Region: "ap-southeast-2"}, discoverer, client, discovery.DefaultRole)
+	assert.ErrorContains(t, err, "failed to assume role")
+}
+
+func TestParsePairs(t *testing.T) {
+	headers, err := parsePairs("Authorization=Bearer abc, X-Scope-OrgID = team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"Authorization": "Bearer abc",
+		"X-Scope-OrgID": "team-a",
+	}, headers)
+
+	_, err = parsePairs("Authorization")
+	assert.Error(t, err)
+}
+
+func TestOptionsFromEnvPeriod(t *testing.T) {
+	t.Setenv("CLOUDFRONT_INVALIDATION_METRICS_PERIOD", "10m")
+
+	options, err := optionsFromEnv(aws.Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, 10*time.Minute, options.Period)
+
+	// A period which is not positive would collect continuously.
+	for _, period := range []string{"0s", "-5m"} {
+		t.Setenv("CLOUDFRONT_INVALIDATION_METRICS_PERIOD", period)
+
+		_, err = optionsFromEnv(aws.Config{})
+		assert.ErrorContains(t, err, "period must be greater than zero")
+	}
+}
+
+func TestClientFromEnvRetryer(t *testing.T) {
+	t.Setenv("CLOUDFRONT_INVALIDATION_METRICS_SINK", SinkCloudWatch)
+
+	client, err := clientFromEnv(aws.Config{Region: "ap-southeast-2"}, Options{})
+	assert.NoError(t, err)
+
+	// Batches are only retried by the client's policy, which has its own
+	// budget, rather than by the SDK as well.
+	cw, ok := client.(*metrics.Client).CloudWatch.(*cloudwatch.Client)
+	assert.True(t, ok)
+	assert.Equal(t, aws.NopRetryer{}, cw.Options().Retryer)
+}
+
+func TestClientFromEnvDryRunEMF(t *testing.T) {
+	t.Setenv("CLOUDFRONT_INVALIDATION_METRICS_SINK", SinkEMF)
+
+	client, err := clientFromEnv(aws.Config{Region: "ap-southeast-2"}, Options{DryRun: true})
+	assert.NoError(t, err)
+
+	// Batches are reported rather than written as EMF lines, which would be
+	// published.
+	reporter, ok := client.(*metrics.Client)
+	assert.True(t, ok)
+	assert.True(t, reporter.DryRun)
+}
+
+func TestClientFromEnvDryRunOTLP(t *testing.T) {
+	t.Setenv("CLOUDFRONT_INVALIDATION_METRICS_SINK", SinkOTLP)
+	t.Setenv("CLOUDFRONT_INVALIDATION_METRICS_OTLP_ENDPOINT", "localhost:4318")
+
+	client, err := clientFromEnv(aws.Config{Region: "ap-southeast-2"}, Options{DryRun: true})
+	assert.NoError(t, err)
+
+	// Batches are reported rather than exported to the collector.
+	reporter, ok := client.(*metrics.Client)
+	assert.True(t, ok)
+	assert.True(t, reporter.DryRun)
+
+	// The sink must still be configured correctly.
+	t.Setenv("CLOUDFRONT_INVALIDATION_METRICS_OTLP_ENDPOINT", "")
+
+	_, err = clientFromEnv(aws.Config{Region: "ap-southeast-2"}, Options{DryRun: true})
+	assert.ErrorContains(t, err, "otlp endpoint is required")
+}
+
+// newInvalidation with the given paths.
+func newInvalidation(id string, items ...string) cftypes.Invalidation {
+	return cftypes.Invalidation{
+		Id: aws.String(id),
+		InvalidationBatch: &cftypes.InvalidationBatch{
+			Paths: &cftypes.Paths{
+				Quantity: aws.Int32(int32(len(items))),
+				Items:    items,
+			},
+		},
+	}
+}
+
+// datumValues indexes datums by "<distribution>/<metric>", skipping datums
+// which break a metric down by path group or source.
+func datumValues(data []types.MetricDatum) map[string]float64 {
+	values := make(map[string]float64)
+
+data:
+	for _, datum := range data {
+		var distribution string
+
+		for _, dimension := range datum.Dimensions {
+			switch aws.ToString(dimension.Name) {
+			case "Distribution":
+				distribution = aws.ToString(dimension.Value)
+			case "PathGroup", "Source":
+				continue data
+			}
+		}
+
+		values[distribution+"/"+aws.ToString(datum.MetricName)] = aws.ToFloat64(datum.Value)
+	}
+
+	return values
+}
+
+// assumeRoleClient issues credentials for every role unless it is missing.
+type assumeRoleClient struct {
+	Missing map[string]bool
+	Err     error
+	Assumed []string
+}
+
+func (c *assumeRoleClient) AssumeRole(ctx context.Context, params *sts.AssumeRoleInput, optFns ...func(*sts.Options)) (*sts.AssumeRoleOutput, error) {
+	roleARN := aws.ToString(params.RoleArn)
+
+	c.Assumed = append(c.Assumed, roleARN)
+
+	if c.Err != nil {
+		return nil, c.Err
+	}
+
+	if c.Missing[roleARN] {
+		return nil, &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized to perform sts:AssumeRole"}
+	}
+
+	return &sts.AssumeRoleOutput{
+		Credentials: &ststypes.Credentials{
+			AccessKeyId:     aws.String("AKIAEXAMPLE"),
+			SecretAccessKey: aws.String("secret"),
+			SessionToken:    aws.String("token"),
+			Expiration:      aws.Time(time.Now().Add(time.Hour)),
+		},
+	}, nil
+}
+
+// deniedClient fails to list distributions, as when a role cannot be assumed.
+type deniedClient struct {
+	cloudfrontclient.MockClient
+}
+
+func (c deniedClient) ListDistributions(ctx context.Context, params *cloudfront.ListDistributionsInput, optFns ...func(*cloudfront.Options)) (*cloudfront.ListDistributionsOutput, error) {
+	return nil, fmt.Errorf("access denied")
+}
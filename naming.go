@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cftypes "github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+)
+
+const (
+	// NameDimensionAlias names distributions by their primary alias.
+	NameDimensionAlias = "alias"
+	// NameDimensionComment names distributions by their comment.
+	NameDimensionComment = "comment"
+)
+
+// parseNameDimension validates how distributions are named.
+func parseNameDimension(value string) (string, error) {
+	switch value {
+	case "", NameDimensionAlias, NameDimensionComment:
+		return value, nil
+	}
+
+	return "", fmt.Errorf("unknown name dimension: %q", value)
+}
+
+// nameDimension returns a human-readable name for the distribution, or
+// nil when it has nothing to be named by.
+func nameDimension(mode string, distribution cftypes.DistributionSummary) *types.Dimension {
+	var name, value string
+
+	switch mode {
+	case NameDimensionAlias:
+		name = "PrimaryAlias"
+
+		if distribution.Aliases != nil {
+			value = primaryAlias(distribution.Aliases.Items)
+		}
+	case NameDimensionComment:
+		name = "Name"
+		value = strings.TrimSpace(aws.ToString(distribution.Comment))
+	}
+
+	// CloudWatch does not accept empty dimension values.
+	if value == "" {
+		return nil
+	}
+
+	return &types.Dimension{
+		Name:  aws.String(name),
+		Value: aws.String(value),
+	}
+}
+
+// primaryAlias selects a single alias so that a distribution is always
+// named the same regardless of the order CloudFront returns its aliases.
+// Aliases which are not wildcards are preferred, followed by those with
+// the fewest labels, the shortest and finally the first alphabetically.
+func primaryAlias(aliases []string) string {
+	if len(aliases) == 0 {
+		return ""
+	}
+
+	sorted := slices.Clone(aliases)
+
+	slices.SortFunc(sorted, func(a, b string) int {
+		if wa, wb := strings.HasPrefix(a, "*"), strings.HasPrefix(b, "*"); wa != wb {
+			if wa {
+				return 1
+			}
+
+			return -1
+		}
+
+		if la, lb := strings.Count(a, "."), strings.Count(b, "."); la != lb {
+			return la - lb
+		}
+
+		if len(a) != len(b) {
+			return len(a) - len(b)
+		}
+
+		return strings.Compare(a, b)
+	})
+
+	return sorted[0]
+}
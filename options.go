@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/alarms"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/billing"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/clock"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/dashboard"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/paths"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/sources"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/state"
+)
+
+// Options which control how Execute collects metrics.
+type Options struct {
+	// Clock tells the time when a run was not triggered by a schedule.
+	// Defaults to the system clock.
+	Clock clock.Interface
+	// DryRun collects metrics without storing checkpoints or managing
+	// alarms and dashboards, so that a real run is not affected by it.
+	DryRun bool
+	// Concurrency is the number of distributions which are processed
+	// at the same time.
+	Concurrency int
+	// Period is how often the Lambda is scheduled to execute, which is
+	// the length of the window measured by each run.
+	Period time.Duration
+	// Lookback is how long after creation the status of an invalidation is
+	// checked for. It should exceed the time invalidations take to complete.
+	Lookback time.Duration
+	// PathGroups classify invalidated paths by their prefix, publishing
+	// a path count for each group.
+	PathGroups paths.Groups
+	// Sources attribute invalidations to a source by their caller
+	// reference, publishing counts for each source.
+	Sources sources.Rules
+	// TagDimensions are the keys of distribution tags which are added to
+	// every metric as dimensions.
+	TagDimensions []string
+	// NameDimension adds a human-readable name for each distribution to
+	// every metric as a dimension, either its primary alias or comment.
+	NameDimension string
+	// PathQuota and WildcardQuota are how many paths and wildcard paths
+	// can be in progress for a distribution at once.
+	PathQuota     float64
+	WildcardQuota float64
+	// Pricing used to estimate the cost of invalidations.
+	Pricing billing.Pricing
+	// Store persists checkpoints between runs. When nil each run counts
+	// the invalidations created within its window.
+	Store state.StoreInterface
+	// Alarms are reconciled for every distribution after metrics have been
+	// pushed. When nil alarms are not managed.
+	Alarms *alarms.Reconciler
+	// AccountID of the account being collected from, which is added to
+	// every metric as a dimension when collecting from many accounts.
+	AccountID string
+	// Dashboard is rendered with graphs for every distribution after
+	// metrics have been pushed. When nil a dashboard is not managed.
+	Dashboard *dashboard.Dashboard
+}
+
+// optionsFromEnv loads Options from environment variables.
+func optionsFromEnv(cfg aws.Config) (Options, error) {
+	options := Options{
+		Clock:         clock.Real{},
+		Concurrency:   1,
+		Period:        DefaultPeriod,
+		Lookback:      DefaultLookback,
+		PathQuota:     DefaultPathQuota,
+		WildcardQuota: DefaultWildcardQuota,
+		Pricing:       billing.DefaultPricing(),
+	}
+
+	var err error
+
+	options.DryRun = os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_DRYRUN") != ""
+
+	if concurrency := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_CONCURRENCY"); concurrency != "" {
+		options.Concurrency, err = strconv.Atoi(concurrency)
+		if err != nil {
+			return options, fmt.Errorf("failed to parse concurrency: %w", err)
+		}
+	}
+
+	if period := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_PERIOD"); period != "" {
+		options.Period, err = time.ParseDuration(period)
+		if err != nil {
+			return options, fmt.Errorf("failed to parse period: %w", err)
+		}
+
+		if options.Period <= 0 {
+			return options, fmt.Errorf("period must be greater than zero: %s", period)
+		}
+	}
+
+	if lookback := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_LOOKBACK"); lookback != "" {
+		options.Lookback, err = time.ParseDuration(lookback)
+		if err != nil {
+			return options, fmt.Errorf("failed to parse lookback: %w", err)
+		}
+	}
+
+	options.PathGroups, err = paths.ParseGroups(os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_PATH_GROUPS"))
+	if err != nil {
+		return options, fmt.Errorf("failed to parse path groups: %w", err)
+	}
+
+	options.Sources, err = sources.ParseRules(os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_SOURCES"))
+	if err != nil {
+		return options, fmt.Errorf("failed to parse sources: %w", err)
+	}
+
+	options.TagDimensions = splitList(os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_TAG_DIMENSIONS"))
+
+	options.NameDimension, err = parseNameDimension(os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_NAME_DIMENSION"))
+	if err != nil {
+		return options, fmt.Errorf("failed to parse name dimension: %w", err)
+	}
+
+	if pathQuota := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_PATH_QUOTA"); pathQuota != "" {
+		options.PathQuota, err = strconv.ParseFloat(pathQuota, 64)
+		if err != nil {
+			return options, fmt.Errorf("failed to parse path quota: %w", err)
+		}
+	}
+
+	if wildcardQuota := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_WILDCARD_QUOTA"); wildcardQuota != "" {
+		options.WildcardQuota, err = strconv.ParseFloat(wildcardQuota, 64)
+		if err != nil {
+			return options, fmt.Errorf("failed to parse wildcard quota: %w", err)
+		}
+	}
+
+	if freePaths := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_FREE_PATHS"); freePaths != "" {
+		options.Pricing.FreePaths, err = strconv.ParseFloat(freePaths, 64)
+		if err != nil {
+			return options, fmt.Errorf("failed to parse free paths: %w", err)
+		}
+	}
+
+	if pricePerPath := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_PRICE_PER_PATH"); pricePerPath != "" {
+		options.Pricing.PricePerPath, err = strconv.ParseFloat(pricePerPath, 64)
+		if err != nil {
+			return options, fmt.Errorf("failed to parse price per path: %w", err)
+		}
+	}
+
+	var (
+		stateFile  = os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_STATE_FILE")
+		stateTable = os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_STATE_TABLE")
+	)
+
+	switch {
+	case stateFile != "" && stateTable != "":
+		return options, fmt.Errorf("state file and state table cannot both be set")
+	case stateFile != "":
+		store, err := state.NewFileStore(stateFile)
+		if err != nil {
+			return options, fmt.Errorf("failed to setup state file: %w", err)
+		}
+
+		options.Store = store
+	case stateTable != "":
+		store, err := state.NewDynamoDBStore(dynamodb.NewFromConfig(cfg), stateTable)
+		if err != nil {
+			return options, fmt.Errorf("failed to setup state table: %w", err)
+		}
+
+		options.Store = store
+	}
+
+	templates, err := alarms.ParseTemplates(os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_ALARMS"))
+	if err != nil {
+		return options, fmt.Errorf("failed to parse alarms: %w", err)
+	}
+
+	if len(templates) > 0 {
+		prefix := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_ALARM_PREFIX")
+		if prefix == "" {
+			prefix = alarms.DefaultPrefix
+		}
+
+		options.Alarms, err = alarms.New(cloudwatch.NewFromConfig(cfg), CloudWatchNamespace, prefix, templates)
+		if err != nil {
+			return options, fmt.Errorf("failed to setup alarms: %w", err)
+		}
+	}
+
+	if name := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_DASHBOARD"); name != "" {
+		options.Dashboard = &dashboard.Dashboard{
+			CloudWatch: cloudwatch.NewFromConfig(cfg),
+			Name:       name,
+			Namespace:  CloudWatchNamespace,
+			Region:     cfg.Region,
+			Period:     options.Period,
+			Graphs:     distributionGraphs,
+		}
+
+		// Completion and billing metrics are only published when state is stored.
+		if options.Store != nil {
+			options.Dashboard.Account = accountGraphs
+			options.Dashboard.Graphs = append(slices.Clone(distributionGraphs), distributionStateGraphs...)
+		}
+	}
+
+	return options, nil
+}
+
+// splitList splits a comma separated list, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
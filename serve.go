@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/metrics/prometheus"
+)
+
+const (
+	// PrometheusPrefix is added to the name of every metric served to Prometheus.
+	PrometheusPrefix = "cloudfront_"
+)
+
+// prometheusTypes of metrics which are counted per window, and so are
+// accumulated into counters. Everything else is a gauge or summary.
+func prometheusTypes() map[string]prometheus.Type {
+	types := make(map[string]prometheus.Type, len(counterMetrics))
+
+	for _, name := range counterMetrics {
+		types[name] = prometheus.Counter
+	}
+
+	return types
+}
+
+// Serve runs as a long-running process which collects metrics every period
+// and serves them to Prometheus on the given address at /metrics.
+func Serve(ctx context.Context, addr string) error {
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get AWS client: %w", err)
+	}
+
+	options, err := optionsFromEnv(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load options: %w", err)
+	}
+
+	accounts, err := accountsFromEnv(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load accounts: %w", err)
+	}
+
+	registry, err := prometheus.New(PrometheusPrefix, prometheusTypes())
+	if err != nil {
+		return fmt.Errorf("failed to setup registry: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	defer server.Shutdown(context.Background())
+
+	var refresh func(context.Context) ([]Account, error)
+
+	// Discovered accounts change as accounts are created and roles are
+	// deployed to them, so they are discovered again every period.
+	if discoverFromEnv() {
+		refresh = func(ctx context.Context) ([]Account, error) {
+			return accountsFromEnv(ctx, cfg)
+		}
+	}
+
+	return collectEvery(ctx, accounts, refresh, registry, options, errs)
+}
+
+// collectEvery period until the context is done or the server fails. The
+// scheduled times are aligned to the period so that windows are contiguous.
+// When refresh is given, the accounts are refreshed before every period
+// after the first, keeping the previous accounts when it fails.
+func collectEvery(ctx context.Context, accounts []Account, refresh func(context.Context) ([]Account, error), registry *prometheus.Registry, options Options, errs <-chan error) error {
+	// A period which is not positive would collect continuously.
+	if options.Period <= 0 {
+		options.Period = DefaultPeriod
+	}
+
+	next := options.Clock.Now().Truncate(options.Period)
+
+	for {
+		// Every account flushes, so gauges are only replaced once all of
+		// them have been collected.
+		registry.Begin()
+
+		// A failed run is retried by the next one rather than stopping the
+		// metrics from being served.
+		err := ExecuteAccounts(ctx, accounts, registry, next, options)
+		if err != nil {
+			log.Printf("failed to collect metrics: %s", err)
+		}
+
+		registry.Commit()
+
+		next = next.Add(options.Period)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			if errors.Is(err, http.ErrServerClosed) {
+				return nil
+			}
+
+			return fmt.Errorf("failed to serve metrics: %w", err)
+		case <-time.After(time.Until(next)):
+		}
+
+		if refresh != nil {
+			refreshed, err := refresh(ctx)
+			if err != nil {
+				log.Printf("failed to refresh accounts: %s", err)
+			} else {
+				accounts = refreshed
+			}
+		}
+	}
+}
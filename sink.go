@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/metrics"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/metrics/emf"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/metrics/otlp"
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/spool"
+)
+
+const (
+	// SinkCloudWatch pushes metrics to CloudWatch with PutMetricData.
+	SinkCloudWatch = "cloudwatch"
+	// SinkEMF writes metrics to stdout in CloudWatch Embedded Metric Format.
+	SinkEMF = "emf"
+	// SinkOTLP exports metrics to an OpenTelemetry collector.
+	SinkOTLP = "otlp"
+)
+
+// clientFromEnv loads the client for the sink which metrics are sent to
+// from environment variables.
+func clientFromEnv(cfg aws.Config, options Options) (metrics.ClientInterface, error) {
+	switch sink := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_SINK"); sink {
+	case SinkCloudWatch, "":
+		// Batches are retried by the client's own policy, so the SDK does not
+		// retry them as well.
+		cw := cloudwatch.NewFromConfig(cfg, func(o *cloudwatch.Options) {
+			o.Retryer = aws.NopRetryer{}
+		})
+
+		client, err := metrics.New(cw, CloudWatchNamespace, options.DryRun)
+		if err != nil {
+			return nil, err
+		}
+
+		if attempts := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_RETRY_ATTEMPTS"); attempts != "" {
+			client.Retry.MaxAttempts, err = strconv.Atoi(attempts)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse retry attempts: %w", err)
+			}
+		}
+
+		if budget := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_RETRY_BUDGET"); budget != "" {
+			client.Retry.Budget, err = strconv.Atoi(budget)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse retry budget: %w", err)
+			}
+		}
+
+		client.Spool, err = spoolFromEnv(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		return client, nil
+	case SinkEMF:
+		// Lines written to stdout are published by CloudWatch Logs, so a dry
+		// run reports the batches instead.
+		if options.DryRun {
+			return metrics.New(nil, CloudWatchNamespace, true)
+		}
+
+		return emf.New(os.Stdout, CloudWatchNamespace)
+	case SinkOTLP:
+		endpoint := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_OTLP_ENDPOINT")
+		if endpoint == "" {
+			return nil, fmt.Errorf("otlp endpoint is required")
+		}
+
+		headers, err := parsePairs(os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_OTLP_HEADERS"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse otlp headers: %w", err)
+		}
+
+		// The configuration is still checked, but a dry run reports the
+		// batches rather than exporting them.
+		if options.DryRun {
+			return metrics.New(nil, CloudWatchNamespace, true)
+		}
+
+		exporter, err := otlp.NewExporter(
+			os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_OTLP_PROTOCOL"),
+			endpoint,
+			headers,
+			os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_OTLP_INSECURE") != "",
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup otlp exporter: %w", err)
+		}
+
+		return otlp.New(exporter, CloudWatchNamespace, options.Period, counterMetrics)
+	default:
+		return nil, fmt.Errorf("unknown sink: %q", sink)
+	}
+}
+
+// spoolFromEnv loads the spool which failed batches are persisted to from
+// environment variables, or nil when none is configured.
+func spoolFromEnv(cfg aws.Config) (spool.StoreInterface, error) {
+	var (
+		dir    = os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_SPOOL_DIR")
+		bucket = os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_SPOOL_BUCKET")
+	)
+
+	switch {
+	case dir != "" && bucket != "":
+		return nil, fmt.Errorf("spool dir and spool bucket cannot both be set")
+	case dir != "":
+		store, err := spool.NewDirectoryStore(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup spool dir: %w", err)
+		}
+
+		return store, nil
+	case bucket != "":
+		client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+			// Allows S3 compatible object stores to be used.
+			if endpoint := os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_SPOOL_ENDPOINT"); endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+				o.UsePathStyle = true
+			}
+		})
+
+		store, err := spool.NewS3Store(client, bucket, os.Getenv("CLOUDFRONT_INVALIDATION_METRICS_SPOOL_PREFIX"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup spool bucket: %w", err)
+		}
+
+		return store, nil
+	}
+
+	return nil, nil
+}
+
+// parsePairs from a comma separated list of key=value pairs, such as headers.
+func parsePairs(value string) (map[string]string, error) {
+	pairs := make(map[string]string)
+
+	for _, item := range splitList(value) {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid pair: %q", item)
+		}
+
+		pairs[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+
+	return pairs, nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"time"
+
+	"github.com/skpr/cloudfront-invalidation-metrics/internal/billing"
+)
+
+const (
+	// DefaultPeriod is how often the Lambda is scheduled to execute.
+	DefaultPeriod = 5 * time.Minute
+	// DefaultLookback is how long the status of invalidations is checked for.
+	DefaultLookback = time.Hour
+)
+
+// Window is the [Start,End) period of time which a run measures.
+type Window struct {
+	Start time.Time
+	End   time.Time
+}
+
+// newWindow which ends at the given time and spans the period before it.
+func newWindow(end time.Time, period time.Duration) Window {
+	return Window{
+		Start: end.Add(-period),
+		End:   end,
+	}
+}
+
+// Month which the window is billed against, being the month its last
+// instant falls within.
+func (w Window) Month() string {
+	return billing.Month(w.End.Add(-time.Nanosecond))
+}